@@ -0,0 +1,104 @@
+package stackdriver
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Event struct {
+	Message string
+	Fields  []zapcore.Field
+}
+
+func (e Event) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", e.Message)
+
+	for _, f := range e.Fields {
+		f.AddTo(enc)
+	}
+
+	return nil
+}
+
+type eventArray []Event
+
+func (e eventArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, ev := range e {
+		if err := enc.AppendObject(ev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type BatchMode int
+
+const (
+	// BatchEach logs one entry per event.
+	BatchEach BatchMode = iota
+	// BatchArray logs a single entry holding all events under the "events" key.
+	BatchArray
+)
+
+// LogBatch logs events at level, either as separate entries or as a single
+// entry depending on mode, with its caller as the caller of the entries.
+func LogBatch(logger *zap.Logger, level zapcore.Level, events []Event, mode BatchMode) {
+	if len(events) == 0 || !logger.Core().Enabled(level) {
+		return
+	}
+
+	logger = logger.WithOptions(zap.AddCallerSkip(1))
+
+	if mode == BatchArray {
+		if ce := logger.Check(level, "batch"); ce != nil {
+			ce.Write(zap.Int("count", len(events)), zap.Array("events", eventArray(events)))
+		}
+
+		return
+	}
+
+	for _, ev := range events {
+		if ce := logger.Check(level, ev.Message); ce != nil {
+			ce.Write(ev.Fields...)
+		}
+	}
+}
+
+type batchResult struct {
+	total     int
+	succeeded int
+	failed    int
+	firstErr  error
+}
+
+func (r *batchResult) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddInt("total", r.total)
+	e.AddInt("succeeded", r.succeeded)
+	e.AddInt("failed", r.failed)
+
+	if r.firstErr != nil {
+		e.AddString("firstError", r.firstErr.Error())
+	}
+
+	return nil
+}
+
+func (r *batchResult) level(lv zapcore.Level) zapcore.Level {
+	if r.failed > 0 && lv < zapcore.WarnLevel {
+		return zapcore.WarnLevel
+	}
+
+	return lv
+}
+
+// LogBatchResult logs the outcome of a batch operation, with the first error
+// it ran into. The entry is at least a WARNING when some items failed.
+func LogBatchResult(total, succeeded, failed int, firstErr error) zapcore.Field {
+	return zap.Object("batchResult", &batchResult{
+		total:     total,
+		succeeded: succeeded,
+		failed:    failed,
+		firstErr:  firstErr,
+	})
+}
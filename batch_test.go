@@ -0,0 +1,137 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogBatch(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	events := []Event{
+		{Message: "foo", Fields: []zapcore.Field{zap.Int("n", 1)}},
+		{Message: "bar", Fields: []zapcore.Field{zap.Int("n", 2)}},
+	}
+
+	t.Run("Each", func(t *testing.T) {
+		defer writer.Reset()
+
+		LogBatch(logger, zapcore.InfoLevel, events, BatchEach)
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 2)
+
+		for i, line := range lines {
+			var actual struct {
+				logEntry
+
+				N int `json:"n"`
+			}
+
+			require.Nil(t, json.Unmarshal([]byte(line), &actual))
+			assert.Equal(t, "INFO", actual.Severity)
+			assert.Equal(t, i+1, actual.N)
+		}
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		defer writer.Reset()
+
+		LogBatch(logger, zapcore.WarnLevel, events, BatchArray)
+
+		var actual struct {
+			logEntry
+
+			Count  int `json:"count"`
+			Events []struct {
+				Message string `json:"message"`
+				N       int    `json:"n"`
+			} `json:"events"`
+		}
+
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "WARNING", actual.Severity)
+		assert.Equal(t, 2, actual.Count)
+		require.Len(t, actual.Events, 2)
+		assert.Equal(t, "foo", actual.Events[0].Message)
+		assert.Equal(t, 1, actual.Events[0].N)
+		assert.Equal(t, "bar", actual.Events[1].Message)
+		assert.Equal(t, 2, actual.Events[1].N)
+	})
+
+	t.Run("Disabled level", func(t *testing.T) {
+		defer writer.Reset()
+
+		LogBatch(logger.WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)), zapcore.InfoLevel, events, BatchEach)
+		assert.Empty(t, writer.String())
+	})
+}
+
+func TestLogBatch_Caller(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer), zap.AddCaller())
+	events := []Event{{Message: "a"}, {Message: "b"}}
+
+	LogBatch(logger, zapcore.InfoLevel, events, BatchEach)
+	LogBatch(logger, zapcore.InfoLevel, events, BatchArray)
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 3)
+
+	for _, line := range lines {
+		var actual struct {
+			Caller string `json:"caller"`
+		}
+
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Contains(t, actual.Caller, "batch_test.go")
+	}
+}
+
+func TestLogBatchResult(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	type batchResultEntry struct {
+		logEntry
+
+		BatchResult map[string]interface{} `json:"batchResult"`
+	}
+
+	log := func(lv zapcore.Level, field zapcore.Field) batchResultEntry {
+		defer writer.Reset()
+
+		logger.Check(lv, "").Write(field)
+
+		var actual batchResultEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual
+	}
+
+	actual := log(zapcore.InfoLevel, LogBatchResult(10, 8, 2, errors.New("item 3: conflict")))
+	assert.Equal(t, "WARNING", actual.Severity)
+	assert.Equal(t, map[string]interface{}{
+		"total":      float64(10),
+		"succeeded":  float64(8),
+		"failed":     float64(2),
+		"firstError": "item 3: conflict",
+	}, actual.BatchResult)
+
+	actual = log(zapcore.InfoLevel, LogBatchResult(10, 10, 0, nil))
+	assert.Equal(t, "INFO", actual.Severity)
+	assert.Equal(t, map[string]interface{}{
+		"total":     float64(10),
+		"succeeded": float64(10),
+		"failed":    float64(0),
+	}, actual.BatchResult)
+
+	assert.Equal(t, "ERROR", log(zapcore.ErrorLevel, LogBatchResult(10, 0, 10, nil)).Severity)
+}
@@ -0,0 +1,78 @@
+package stackdriver
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// boolsAsStrings replaces booleans in fields with their string form,
+// including the ones nested in objects and arrays. Values logged with
+// reflection are left untouched.
+func boolsAsStrings(fields []zapcore.Field) []zapcore.Field {
+	output := make([]zapcore.Field, len(fields))
+
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.BoolType:
+			output[i] = zap.String(f.Key, strconv.FormatBool(f.Integer == 1))
+		case zapcore.ObjectMarshalerType:
+			output[i] = zap.Object(f.Key, boolStringObject{f.Interface.(zapcore.ObjectMarshaler)})
+		case zapcore.ArrayMarshalerType:
+			output[i] = zap.Array(f.Key, boolStringArray{f.Interface.(zapcore.ArrayMarshaler)})
+		default:
+			output[i] = f
+		}
+	}
+
+	return output
+}
+
+type boolStringObject struct {
+	zapcore.ObjectMarshaler
+}
+
+func (o boolStringObject) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	return o.ObjectMarshaler.MarshalLogObject(boolStringObjectEncoder{e})
+}
+
+type boolStringArray struct {
+	zapcore.ArrayMarshaler
+}
+
+func (a boolStringArray) MarshalLogArray(e zapcore.ArrayEncoder) error {
+	return a.ArrayMarshaler.MarshalLogArray(boolStringArrayEncoder{e})
+}
+
+type boolStringObjectEncoder struct {
+	zapcore.ObjectEncoder
+}
+
+func (e boolStringObjectEncoder) AddBool(key string, value bool) {
+	e.ObjectEncoder.AddString(key, strconv.FormatBool(value))
+}
+
+func (e boolStringObjectEncoder) AddObject(key string, m zapcore.ObjectMarshaler) error {
+	return e.ObjectEncoder.AddObject(key, boolStringObject{m})
+}
+
+func (e boolStringObjectEncoder) AddArray(key string, m zapcore.ArrayMarshaler) error {
+	return e.ObjectEncoder.AddArray(key, boolStringArray{m})
+}
+
+type boolStringArrayEncoder struct {
+	zapcore.ArrayEncoder
+}
+
+func (e boolStringArrayEncoder) AppendBool(value bool) {
+	e.ArrayEncoder.AppendString(strconv.FormatBool(value))
+}
+
+func (e boolStringArrayEncoder) AppendObject(m zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(boolStringObject{m})
+}
+
+func (e boolStringArrayEncoder) AppendArray(m zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(boolStringArray{m})
+}
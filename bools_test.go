@@ -0,0 +1,62 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type boolObject struct{}
+
+func (boolObject) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddBool("bool", true)
+	return e.AddArray("array", zapcore.ArrayMarshalerFunc(func(e zapcore.ArrayEncoder) error {
+		e.AppendBool(false)
+		return nil
+	}))
+}
+
+func TestCore_BoolsAsStrings(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+
+	t.Run("Disabled", func(t *testing.T) {
+		defer writer.Reset()
+
+		zap.New(newCore(writer)).
+			With(zap.Bool("foo", true)).
+			Info("", zap.Bool("bar", false), zap.Object("baz", boolObject{}))
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, true, actual["foo"])
+		assert.Equal(t, false, actual["bar"])
+		assert.Equal(t, map[string]interface{}{
+			"bool":  true,
+			"array": []interface{}{false},
+		}, actual["baz"])
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.BoolsAsStrings = true
+		zap.New(core).
+			With(zap.Bool("foo", true)).
+			Info("", zap.Bool("bar", false), zap.Object("baz", boolObject{}))
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "true", actual["foo"])
+		assert.Equal(t, "false", actual["bar"])
+		assert.Equal(t, map[string]interface{}{
+			"bool":  "true",
+			"array": []interface{}{"false"},
+		}, actual["baz"])
+	})
+}
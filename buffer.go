@@ -0,0 +1,230 @@
+package stackdriver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultBufferSize    = 256 * 1024
+	defaultFlushInterval = time.Second
+	defaultQueueDepth    = 1024
+)
+
+// BufferedWriteSyncer buffers the entries written to a WriteSyncer, which are
+// written in batches by a background goroutine once the buffer is full or
+// every flush interval, whichever comes first. Sync writes the entries
+// buffered up to the call before syncing, and the Core calls it before
+// writing entries above ERROR, so that the entries aren't lost when the
+// process panics or exits.
+type BufferedWriteSyncer struct {
+	ws       zapcore.WriteSyncer
+	size     int
+	interval time.Duration
+	depth    int
+	drop     bool
+
+	// mu is held for writing by Stop, so that no entry is queued once the
+	// goroutine has stopped.
+	mu      sync.RWMutex
+	stopped bool
+	queue   chan []byte
+	syncs   chan chan error
+	done    chan chan error
+	dropped uint64
+
+	// buf and err are only used by the goroutine.
+	buf []byte
+	err error
+}
+
+type BufferOption func(*BufferedWriteSyncer)
+
+// WithBufferSize sets the number of bytes buffered before they are written,
+// which defaults to 256 KiB.
+func WithBufferSize(size int) BufferOption {
+	return func(s *BufferedWriteSyncer) {
+		s.size = size
+	}
+}
+
+// WithFlushInterval sets how often the buffer is written when it isn't full,
+// which defaults to a second. An interval of 0 or less is ignored.
+func WithFlushInterval(d time.Duration) BufferOption {
+	return func(s *BufferedWriteSyncer) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithQueueDepth sets the number of entries which can be waiting for the
+// background goroutine, while it writes to the WriteSyncer, before further
+// entries block or are dropped. It defaults to 1024.
+func WithQueueDepth(n int) BufferOption {
+	return func(s *BufferedWriteSyncer) {
+		s.depth = n
+	}
+}
+
+// WithDropWhenFull drops the entries written while the queue is full rather
+// than blocking until there's room, such as when the WriteSyncer is slow.
+// Dropped returns how many were dropped.
+func WithDropWhenFull() BufferOption {
+	return func(s *BufferedWriteSyncer) {
+		s.drop = true
+	}
+}
+
+// NewBufferedWriteSyncer returns a BufferedWriteSyncer writing to ws, for
+// NewJSONCore or NewSplitCore. Call Stop, typically deferred, to write the
+// buffered entries and stop its goroutine.
+func NewBufferedWriteSyncer(ws zapcore.WriteSyncer, opts ...BufferOption) *BufferedWriteSyncer {
+	s := &BufferedWriteSyncer{
+		ws:       ws,
+		size:     defaultBufferSize,
+		interval: defaultFlushInterval,
+		depth:    defaultQueueDepth,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.queue = make(chan []byte, s.depth)
+	s.syncs = make(chan chan error)
+	s.done = make(chan chan error)
+
+	go s.run()
+
+	return s
+}
+
+// Write queues a copy of p to be written. It writes to the WriteSyncer
+// directly once the BufferedWriteSyncer is stopped.
+func (s *BufferedWriteSyncer) Write(p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return s.ws.Write(p)
+	}
+
+	b := append([]byte(nil), p...)
+
+	if !s.drop {
+		s.queue <- b
+		return len(p), nil
+	}
+
+	select {
+	case s.queue <- b:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+
+	return len(p), nil
+}
+
+// Sync writes the entries written before it was called and syncs the
+// WriteSyncer. It returns the first error the entries were written with since
+// the last call, if any.
+func (s *BufferedWriteSyncer) Sync() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return s.ws.Sync()
+	}
+
+	reply := make(chan error, 1)
+	s.syncs <- reply
+	return <-reply
+}
+
+// Stop writes the buffered entries and stops the background goroutine, after
+// which entries are written directly. Only the first call does anything.
+func (s *BufferedWriteSyncer) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil
+	}
+
+	s.stopped = true
+	reply := make(chan error, 1)
+	s.done <- reply
+	return <-reply
+}
+
+// Dropped returns the number of entries dropped because the queue was full,
+// with WithDropWhenFull.
+func (s *BufferedWriteSyncer) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *BufferedWriteSyncer) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case b := <-s.queue:
+			s.buf = append(s.buf, b...)
+
+			if len(s.buf) >= s.size {
+				s.flush()
+			}
+		case <-ticker.C:
+			s.flush()
+		case reply := <-s.syncs:
+			reply <- s.sync()
+		case reply := <-s.done:
+			reply <- s.sync()
+			return
+		}
+	}
+}
+
+// sync writes the queued and buffered entries and syncs the WriteSyncer.
+func (s *BufferedWriteSyncer) sync() error {
+	s.drain()
+	s.flush()
+
+	err := s.err
+	s.err = nil
+
+	if syncErr := s.ws.Sync(); err == nil {
+		err = syncErr
+	}
+
+	return err
+}
+
+// drain buffers the queued entries.
+func (s *BufferedWriteSyncer) drain() {
+	for {
+		select {
+		case b := <-s.queue:
+			s.buf = append(s.buf, b...)
+		default:
+			return
+		}
+	}
+}
+
+func (s *BufferedWriteSyncer) flush() {
+	if len(s.buf) == 0 {
+		return
+	}
+
+	if _, err := s.ws.Write(s.buf); err != nil && s.err == nil {
+		s.err = err
+	}
+
+	s.buf = s.buf[:0]
+}
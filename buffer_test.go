@@ -0,0 +1,163 @@
+package stackdriver
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// lockedBuffer is a WriteSyncer which can be read while being written to.
+type lockedBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	syncs int
+	err   error
+
+	// block, when set, is received from before every write.
+	block chan struct{}
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	if b.block != nil {
+		<-b.block
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.syncs++
+	return nil
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestBufferedWriteSyncer(t *testing.T) {
+	t.Run("Sync", func(t *testing.T) {
+		ws := &lockedBuffer{}
+		s := NewBufferedWriteSyncer(ws, WithFlushInterval(time.Hour))
+		defer s.Stop()
+		logger := zap.New(NewJSONCore(s, zapcore.InfoLevel))
+
+		logger.Info("foo")
+		logger.Info("bar")
+		assert.Empty(t, ws.String())
+
+		assert.Nil(t, logger.Sync())
+		assert.Equal(t, 2, strings.Count(ws.String(), "\n"))
+		assert.Equal(t, 1, ws.syncs)
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		ws := &lockedBuffer{}
+		s := NewBufferedWriteSyncer(ws, WithBufferSize(8), WithFlushInterval(time.Hour))
+		defer s.Stop()
+
+		s.Write([]byte("0123"))
+		s.Write([]byte("4567"))
+
+		for i := 0; i < 100 && ws.String() == ""; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.Equal(t, "01234567", ws.String())
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		ws := &lockedBuffer{}
+		s := NewBufferedWriteSyncer(ws, WithFlushInterval(time.Millisecond))
+		defer s.Stop()
+
+		s.Write([]byte("foo"))
+
+		for i := 0; i < 100 && ws.String() == ""; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.Equal(t, "foo", ws.String())
+	})
+
+	t.Run("Non-positive interval", func(t *testing.T) {
+		s := NewBufferedWriteSyncer(&lockedBuffer{}, WithFlushInterval(0))
+		defer s.Stop()
+
+		assert.Equal(t, defaultFlushInterval, s.interval)
+	})
+
+	t.Run("Drop when full", func(t *testing.T) {
+		ws := &lockedBuffer{block: make(chan struct{})}
+		s := NewBufferedWriteSyncer(ws, WithBufferSize(1), WithQueueDepth(1), WithDropWhenFull())
+
+		// The goroutine blocks writing the first entry, the second is queued
+		// and the third is dropped.
+		s.Write([]byte("a"))
+		for i := 0; i < 100 && len(s.queue) > 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		s.Write([]byte("b"))
+		s.Write([]byte("c"))
+		assert.Equal(t, uint64(1), s.Dropped())
+
+		close(ws.block)
+		assert.Nil(t, s.Stop())
+		assert.Equal(t, "ab", ws.String())
+	})
+
+	t.Run("Write error", func(t *testing.T) {
+		err := errors.New("foo")
+		ws := &lockedBuffer{err: err}
+		s := NewBufferedWriteSyncer(ws)
+		defer s.Stop()
+
+		s.Write([]byte("foo"))
+		assert.Equal(t, err, s.Sync())
+		assert.Nil(t, s.Sync())
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		ws := &lockedBuffer{}
+		s := NewBufferedWriteSyncer(ws)
+
+		s.Write([]byte("foo"))
+		assert.Nil(t, s.Stop())
+		assert.Nil(t, s.Stop())
+		assert.Equal(t, "foo", ws.String())
+
+		s.Write([]byte("bar"))
+		assert.Equal(t, "foobar", ws.String())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		ws := &lockedBuffer{}
+		s := NewBufferedWriteSyncer(ws, WithFlushInterval(time.Hour))
+		defer s.Stop()
+		logger := zap.New(NewJSONCore(s, zapcore.InfoLevel))
+
+		assert.Panics(t, func() {
+			logger.Panic("foo")
+		})
+		assert.Contains(t, ws.String(), `"message":"foo"`)
+	})
+}
@@ -0,0 +1,76 @@
+package stackdriver
+
+import (
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redactedValue = "[REDACTED]"
+
+// SensitiveConfigKeys are the substrings, matched regardless of case, of the
+// config keys whose values LogConfigReload redacts.
+var SensitiveConfigKeys = []string{"password", "secret", "token", "apikey", "api_key", "credential", "private"}
+
+func isSensitiveConfigKey(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, s := range SensitiveConfigKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type configChanges map[string]interface{}
+
+func (c configChanges) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(c))
+
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if isSensitiveConfigKey(k) {
+			e.AddString(k, redactedValue)
+			continue
+		}
+
+		if err := e.AddReflected(k, c[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type configReload struct {
+	source  string
+	changed configChanges
+}
+
+func (r *configReload) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("source", r.source)
+	return e.AddObject("changed", r.changed)
+}
+
+func (r *configReload) level(zapcore.Level) zapcore.Level {
+	return noticeLevel
+}
+
+// LogConfigReload logs that the config was reloaded from source, with the new
+// values of the keys which changed. The values of keys matching
+// SensitiveConfigKeys are redacted. The entry is a NOTICE.
+func LogConfigReload(source string, changed map[string]interface{}) zapcore.Field {
+	return zap.Object("configReload", &configReload{
+		source:  source,
+		changed: configChanges(changed),
+	})
+}
@@ -0,0 +1,44 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLogConfigReload(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	logger.Info("", LogConfigReload("/etc/app/config.yaml", map[string]interface{}{
+		"workers":        8,
+		"cache.ttl":      "5m",
+		"db.password":    "hunter2",
+		"stripe.API_KEY": "sk_live_foo",
+		"githubToken":    "ghp_foo",
+	}))
+
+	var actual struct {
+		logEntry
+
+		ConfigReload map[string]interface{} `json:"configReload"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "NOTICE", actual.Severity)
+	assert.Equal(t, map[string]interface{}{
+		"source": "/etc/app/config.yaml",
+		"changed": map[string]interface{}{
+			"workers":        float64(8),
+			"cache.ttl":      "5m",
+			"db.password":    "[REDACTED]",
+			"stripe.API_KEY": "[REDACTED]",
+			"githubToken":    "[REDACTED]",
+		},
+	}, actual.ConfigReload)
+	assert.NotContains(t, writer.String(), "hunter2")
+}
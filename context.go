@@ -1,6 +1,11 @@
 package stackdriver
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
 	"go.uber.org/zap/zapcore"
 )
 
@@ -28,11 +33,34 @@ type Context struct {
 	User           string          `json:"user"`
 	HTTPRequest    *HTTPRequest    `json:"httpRequest"`
 	ReportLocation *ReportLocation `json:"reportLocation"`
+
+	labels       map[string]string
+	operation    *Operation
+	trace        string
+	spanID       string
+	traceSampled bool
+	errorGroup   string
+	insertID     string
+	// sourceLocation is only set by Write, for the entry being written.
+	sourceLocation *ReportLocation
 }
 
 func (c *Context) Clone() *Context {
 	output := &Context{
-		User: c.User,
+		User:         c.User,
+		trace:        c.trace,
+		spanID:       c.spanID,
+		traceSampled: c.traceSampled,
+		errorGroup:   c.errorGroup,
+		insertID:     c.insertID,
+	}
+
+	if c.labels != nil {
+		output.labels = make(map[string]string, len(c.labels))
+
+		for k, v := range c.labels {
+			output.labels[k] = v
+		}
 	}
 
 	if c.HTTPRequest != nil {
@@ -43,6 +71,10 @@ func (c *Context) Clone() *Context {
 		output.ReportLocation = c.ReportLocation.Clone()
 	}
 
+	if c.operation != nil {
+		output.operation = c.operation.Clone()
+	}
+
 	return output
 }
 
@@ -66,6 +98,38 @@ func (c *Context) MarshalLogObject(e zapcore.ObjectEncoder) (err error) {
 	return
 }
 
+func (c *Context) isEmpty() bool {
+	return c.User == "" && c.HTTPRequest == nil && c.ReportLocation == nil
+}
+
+func (c *Context) setLabel(key, value string) {
+	if c.labels == nil {
+		c.labels = map[string]string{}
+	}
+
+	c.labels[key] = value
+}
+
+type stringMap map[string]string
+
+// MarshalLogObject adds the entries sorted by key so that the output is
+// deterministic.
+func (m stringMap) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		e.AddString(k, m[k])
+	}
+
+	return nil
+}
+
 type HTTPRequest struct {
 	Method             string `json:"method"`
 	URL                string `json:"url"`
@@ -73,26 +137,168 @@ type HTTPRequest struct {
 	Referrer           string `json:"referrer"`
 	ResponseStatusCode int    `json:"responseStatusCode"`
 	RemoteIP           string `json:"remoteIp"`
+
+	// Latency is how long the request took to be answered. It's written as
+	// a number of seconds with a trailing "s", such as "3.500s".
+	Latency time.Duration `json:"-"`
+
+	// RequestSize and ResponseSize are the sizes in bytes of the request and
+	// its response. They are written as strings.
+	RequestSize  int64 `json:"requestSize,string,omitempty"`
+	ResponseSize int64 `json:"responseSize,string,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+	TLS     *TLSInfo          `json:"tls,omitempty"`
 }
 
 func (h *HTTPRequest) Clone() *HTTPRequest {
-	return &HTTPRequest{
+	output := &HTTPRequest{
 		Method:             h.Method,
 		URL:                h.URL,
 		UserAgent:          h.UserAgent,
 		Referrer:           h.Referrer,
 		ResponseStatusCode: h.ResponseStatusCode,
 		RemoteIP:           h.RemoteIP,
+		Latency:            h.Latency,
+		RequestSize:        h.RequestSize,
+		ResponseSize:       h.ResponseSize,
+	}
+
+	if h.Headers != nil {
+		output.Headers = make(map[string]string, len(h.Headers))
+
+		for k, v := range h.Headers {
+			output.Headers[k] = v
+		}
+	}
+
+	if h.TLS != nil {
+		output.TLS = h.TLS.Clone()
 	}
+
+	return output
 }
 
 func (h *HTTPRequest) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	e.AddString("method", h.Method)
 	e.AddString("url", h.URL)
-	e.AddString("userAgent", h.UserAgent)
-	e.AddString("referrer", h.Referrer)
+
+	if h.UserAgent != "" {
+		e.AddString("userAgent", h.UserAgent)
+	}
+
+	if h.Referrer != "" {
+		e.AddString("referrer", h.Referrer)
+	}
+
 	e.AddInt("responseStatusCode", h.ResponseStatusCode)
 	e.AddString("remoteIp", h.RemoteIP)
+
+	if h.Latency > 0 {
+		e.AddString("latency", formatLatency(h.Latency))
+	}
+
+	if h.RequestSize > 0 {
+		e.AddString("requestSize", strconv.FormatInt(h.RequestSize, 10))
+	}
+
+	if h.ResponseSize > 0 {
+		e.AddString("responseSize", strconv.FormatInt(h.ResponseSize, 10))
+	}
+
+	if len(h.Headers) > 0 {
+		if err := e.AddObject("headers", stringMap(h.Headers)); err != nil {
+			return err
+		}
+	}
+
+	if h.TLS != nil {
+		return e.AddObject("tls", h.TLS)
+	}
+
+	return nil
+}
+
+// logEntryHTTPRequest writes an HTTPRequest like the httpRequest of a
+// LogEntry, which the request viewer of Cloud Logging reads, whereas the
+// httpRequest of the context is read by Error Reporting.
+type logEntryHTTPRequest struct {
+	*HTTPRequest
+}
+
+func (h logEntryHTTPRequest) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	if h.Method != "" {
+		e.AddString("requestMethod", h.Method)
+	}
+
+	if h.URL != "" {
+		e.AddString("requestUrl", h.URL)
+	}
+
+	if h.ResponseStatusCode != 0 {
+		e.AddInt("status", h.ResponseStatusCode)
+	}
+
+	if h.Latency > 0 {
+		e.AddString("latency", formatLatency(h.Latency))
+	}
+
+	if h.RequestSize > 0 {
+		e.AddString("requestSize", strconv.FormatInt(h.RequestSize, 10))
+	}
+
+	if h.ResponseSize > 0 {
+		e.AddString("responseSize", strconv.FormatInt(h.ResponseSize, 10))
+	}
+
+	if h.UserAgent != "" {
+		e.AddString("userAgent", h.UserAgent)
+	}
+
+	if h.RemoteIP != "" {
+		e.AddString("remoteIp", h.RemoteIP)
+	}
+
+	if h.Referrer != "" {
+		e.AddString("referer", h.Referrer)
+	}
+
+	return nil
+}
+
+// formatLatency formats d like the JSON form of a protobuf Duration, with 0,
+// 3, 6 or 9 fractional digits.
+func formatLatency(d time.Duration) string {
+	secs := d / time.Second
+	nanos := d % time.Second
+
+	switch {
+	case nanos == 0:
+		return fmt.Sprintf("%ds", secs)
+	case nanos%time.Millisecond == 0:
+		return fmt.Sprintf("%d.%03ds", secs, nanos/time.Millisecond)
+	case nanos%time.Microsecond == 0:
+		return fmt.Sprintf("%d.%06ds", secs, nanos/time.Microsecond)
+	}
+
+	return fmt.Sprintf("%d.%09ds", secs, nanos)
+}
+
+type TLSInfo struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipherSuite"`
+}
+
+func (t *TLSInfo) Clone() *TLSInfo {
+	return &TLSInfo{
+		Version:     t.Version,
+		CipherSuite: t.CipherSuite,
+	}
+}
+
+func (t *TLSInfo) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("version", t.Version)
+	e.AddString("cipherSuite", t.CipherSuite)
 	return nil
 }
 
@@ -116,3 +322,20 @@ func (r *ReportLocation) MarshalLogObject(e zapcore.ObjectEncoder) error {
 	e.AddString("functionName", r.FunctionName)
 	return nil
 }
+
+// sourceLocation writes a location as a LogEntrySourceLocation, whose line is
+// a string.
+type sourceLocation struct {
+	*ReportLocation
+}
+
+func (s sourceLocation) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("file", s.FilePath)
+	e.AddString("line", strconv.Itoa(s.LineNumber))
+
+	if s.FunctionName != "" {
+		e.AddString("function", s.FunctionName)
+	}
+
+	return nil
+}
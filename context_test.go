@@ -1,10 +1,15 @@
 package stackdriver
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestServiceContext_Clone(t *testing.T) {
@@ -64,6 +69,11 @@ func TestHTTPRequest_Clone(t *testing.T) {
 		Referrer:           "baz",
 		ResponseStatusCode: 200,
 		RemoteIP:           "1.2.3.4",
+		Latency:            time.Second,
+		RequestSize:        10,
+		ResponseSize:       20,
+		Headers:            map[string]string{"Accept": "text/html"},
+		TLS:                &TLSInfo{Version: "TLS 1.3"},
 	}
 
 	res := src.Clone()
@@ -91,6 +101,115 @@ func TestHTTPRequest_MarshalLogObject(t *testing.T) {
 	enc.AssertExpectations(t)
 }
 
+func TestHTTPRequest_MarshalLogObject_Headers(t *testing.T) {
+	enc := new(ObjectEncoder)
+	req := &HTTPRequest{
+		Method:             "GET",
+		URL:                "/foo",
+		ResponseStatusCode: 200,
+		RemoteIP:           "1.2.3.4",
+		Headers:            map[string]string{"Accept": "text/html"},
+	}
+
+	enc.On("AddString", "method", req.Method).Once()
+	enc.On("AddString", "url", req.URL).Once()
+	enc.On("AddInt", "responseStatusCode", req.ResponseStatusCode).Once()
+	enc.On("AddString", "remoteIp", req.RemoteIP).Once()
+	enc.On("AddObject", "headers", stringMap(req.Headers)).Return(nil).Once()
+	require.Nil(t, req.MarshalLogObject(enc))
+	enc.AssertExpectations(t)
+}
+
+func TestHTTPRequest_MarshalLogObject_OmitEmpty(t *testing.T) {
+	enc := new(ObjectEncoder)
+	req := &HTTPRequest{
+		Method:             "GET",
+		URL:                "/foo",
+		ResponseStatusCode: 200,
+		RemoteIP:           "1.2.3.4",
+	}
+
+	enc.On("AddString", "method", req.Method).Once()
+	enc.On("AddString", "url", req.URL).Once()
+	enc.On("AddInt", "responseStatusCode", req.ResponseStatusCode).Once()
+	enc.On("AddString", "remoteIp", req.RemoteIP).Once()
+	require.Nil(t, req.MarshalLogObject(enc))
+	enc.AssertExpectations(t)
+}
+
+func TestHTTPRequest_MarshalLogObject_LatencyAndSizes(t *testing.T) {
+	enc := zapcore.NewMapObjectEncoder()
+	req := &HTTPRequest{
+		Method:             "POST",
+		URL:                "/foo",
+		ResponseStatusCode: 201,
+		RemoteIP:           "1.2.3.4",
+		Latency:            3500 * time.Millisecond,
+		RequestSize:        512,
+		ResponseSize:       2048,
+	}
+
+	require.Nil(t, req.MarshalLogObject(enc))
+	assert.Equal(t, map[string]interface{}{
+		"method":             "POST",
+		"url":                "/foo",
+		"responseStatusCode": 201,
+		"remoteIp":           "1.2.3.4",
+		"latency":            "3.500s",
+		"requestSize":        "512",
+		"responseSize":       "2048",
+	}, enc.Fields)
+}
+
+func TestCore_RootHTTPRequest(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	logger.Info("", LogHTTPRequest(&HTTPRequest{
+		Method:             "POST",
+		URL:                "https://example.com/foo",
+		UserAgent:          "agent",
+		Referrer:           "https://example.com/",
+		ResponseStatusCode: 201,
+		RemoteIP:           "1.2.3.4",
+		Latency:            3500 * time.Millisecond,
+		RequestSize:        512,
+		ResponseSize:       2048,
+	}))
+
+	var actual struct {
+		HTTPRequest map[string]interface{} `json:"httpRequest"`
+		Context     struct {
+			HTTPRequest map[string]interface{} `json:"httpRequest"`
+		} `json:"context"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]interface{}{
+		"requestMethod": "POST",
+		"requestUrl":    "https://example.com/foo",
+		"status":        float64(201),
+		"latency":       "3.500s",
+		"requestSize":   "512",
+		"responseSize":  "2048",
+		"userAgent":     "agent",
+		"remoteIp":      "1.2.3.4",
+		"referer":       "https://example.com/",
+	}, actual.HTTPRequest)
+	assert.Equal(t, "POST", actual.Context.HTTPRequest["method"])
+}
+
+func TestFormatLatency(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		3500 * time.Millisecond:       "3.500s",
+		2 * time.Second:               "2s",
+		1500 * time.Microsecond:       "0.001500s",
+		time.Second + time.Nanosecond: "1.000000001s",
+	} {
+		assert.Equal(t, expected, formatLatency(d))
+	}
+}
+
 func TestReportLocation_Clone(t *testing.T) {
 	src := &ReportLocation{
 		FilePath:     "foo",
@@ -116,3 +235,16 @@ func TestReportLocation_MarshalLogObject(t *testing.T) {
 	require.Nil(t, loc.MarshalLogObject(enc))
 	enc.AssertExpectations(t)
 }
+
+func TestTLSInfo_MarshalLogObject(t *testing.T) {
+	enc := new(ObjectEncoder)
+	info := &TLSInfo{
+		Version:     "TLS 1.3",
+		CipherSuite: "TLS_AES_128_GCM_SHA256",
+	}
+
+	enc.On("AddString", "version", info.Version).Once()
+	enc.On("AddString", "cipherSuite", info.CipherSuite).Once()
+	require.Nil(t, info.MarshalLogObject(enc))
+	enc.AssertExpectations(t)
+}
@@ -1,12 +1,17 @@
 package stackdriver
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,13 +20,83 @@ import (
 const (
 	logKeyServiceContext        = "serviceContext"
 	logKeyContextHTTPRequest    = "context.httpRequest"
+	logKeyHTTPRequest           = "httpRequest"
 	logKeyContextUser           = "context.user"
 	logKeyContextReportLocation = "context.reportLocation"
+	logKeyLabels                = "logging.googleapis.com/labels"
+	logKeyOperation             = "logging.googleapis.com/operation"
+	logKeyTrace                 = "logging.googleapis.com/trace"
+	logKeySpanID                = "logging.googleapis.com/spanId"
+	logKeyTraceSampled          = "logging.googleapis.com/trace_sampled"
+	logKeySourceLocation        = "logging.googleapis.com/sourceLocation"
+	logKeyInsertID              = "logging.googleapis.com/insertId"
+	logKeyType                  = "@type"
+	logKeyErrorGroup            = "errorGroup"
 )
 
+const labelErrorGroup = "errorGroup"
+
+const typeReportedErrorEvent = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+const (
+	defaultMaxLabelValueLength = 64 * 1024
+	labelTruncatedMarker       = "...(truncated)"
+)
+
+// leveler is implemented by field values which change the level of the entry
+// they are logged with.
+type leveler interface {
+	level(lv zapcore.Level) zapcore.Level
+}
+
+// fieldsLevel returns the level of an entry logged at lv once changed by the
+// levelers of fields.
+func fieldsLevel(lv zapcore.Level, fields []zapcore.Field) zapcore.Level {
+	for _, f := range fields {
+		if l, ok := f.Interface.(leveler); ok {
+			lv = l.level(lv)
+		}
+	}
+
+	return lv
+}
+
+// labeler is implemented by field values which add labels to the entry they
+// are logged with.
+type labeler interface {
+	labels() map[string]string
+}
+
+// locator is implemented by field values which set the report location of
+// the entry they are logged with.
+type locator interface {
+	reportLocation() *ReportLocation
+}
+
+// operator is implemented by field values which set the operation of the
+// entry they are logged with.
+type operator interface {
+	operation() *Operation
+}
+
+// noticeLevel is the level the Core gives to entries written with the NOTICE
+// severity. zap has no room for a level between InfoLevel and WarnLevel, so
+// it's outside of zap's range, is never used to log, and is enabled whenever
+// InfoLevel is.
+const noticeLevel = zapcore.Level(64)
+
+// defaultLevel is the level the Core gives to entries written with the
+// DEFAULT severity, which Cloud Logging uses for entries without a known
+// severity.
+const defaultLevel = zapcore.Level(65)
+
+const severityDefault = "DEFAULT"
+
 var logLevelSeverity = map[zapcore.Level]string{
+	defaultLevel:        severityDefault,
 	zapcore.DebugLevel:  "DEBUG",
 	zapcore.InfoLevel:   "INFO",
+	noticeLevel:         "NOTICE",
 	zapcore.WarnLevel:   "WARNING",
 	zapcore.ErrorLevel:  "ERROR",
 	zapcore.DPanicLevel: "CRITICAL",
@@ -29,6 +104,17 @@ var logLevelSeverity = map[zapcore.Level]string{
 	zapcore.FatalLevel:  "EMERGENCY",
 }
 
+// severityLevel maps the severities in logLevelSeverity back to their levels.
+var severityLevel = func() map[string]zapcore.Level {
+	m := make(map[string]zapcore.Level, len(logLevelSeverity))
+
+	for lv, s := range logLevelSeverity {
+		m[s] = lv
+	}
+
+	return m
+}()
+
 var EncoderConfig = zapcore.EncoderConfig{
 	TimeKey:        "timestamp",
 	LevelKey:       "severity",
@@ -38,7 +124,7 @@ var EncoderConfig = zapcore.EncoderConfig{
 	StacktraceKey:  "stacktrace",
 	LineEnding:     zapcore.DefaultLineEnding,
 	EncodeLevel:    EncodeLevel,
-	EncodeTime:     zapcore.ISO8601TimeEncoder,
+	EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
 	EncodeDuration: zapcore.MillisDurationEncoder,
 	EncodeCaller:   zapcore.ShortCallerEncoder,
 }
@@ -48,21 +134,170 @@ type Core struct {
 
 	SetReportLocation bool
 
+	// SetSourceLocation writes the caller of entries as their source
+	// location, which Cloud Logging shows alongside entries of any severity,
+	// unlike the report location which is only meant for Error Reporting.
+	// Entries without a caller use their report location, if any. The
+	// legacy context.reportLocation is only written with SetReportLocation or
+	// LogReportLocation.
+	SetSourceLocation bool
+
+	// MessageFields appends the fields of entries to their message, formatted
+	// as "key=value", on top of writing them as structured keys.
+	MessageFields bool
+
+	// AutoPayload leaves out the context of entries which don't have any, and
+	// stops appending fields to the message even when MessageFields is set.
+	// Entries without any fields are then written with the message as their
+	// only payload, which Cloud Logging stores as textPayload, while entries
+	// with fields keep them as structured jsonPayload keys.
+	AutoPayload bool
+
+	// MaxLabelValueLength is the maximum length in bytes of a label value.
+	// Longer values are truncated and end with labelTruncatedMarker. Defaults
+	// to the Cloud Logging limit of 64 KiB.
+	MaxLabelValueLength int
+
+	// BoolsAsStrings writes boolean field values as "true" or "false" strings
+	// for consumers which can't handle JSON booleans.
+	BoolsAsStrings bool
+
+	// LevelSeverity overrides the severity entries are written with, by the
+	// level they are logged at, such as to write WARN entries as NOTICE. Its
+	// values must be severities known to Cloud Logging, others are written as
+	// DEFAULT. It must not be changed once the Core is used.
+	LevelSeverity map[zapcore.Level]string
+
+	// ErrorOutput receives diagnostics about entries which are written
+	// differently than requested, such as a span ID dropped because the entry
+	// has no trace. Nothing is reported when it's nil.
+	ErrorOutput zapcore.WriteSyncer
+
 	ctx *Context
+
+	remapDPanic    bool
+	dpanicLevel    zapcore.Level
+	version        string
+	serviceContext *ServiceContext
+	correlations   []correlation
+	stackFrames    int
+	stackHash      bool
+	uptime         bool
+	sampledDebug   bool
+	samplingPrio   bool
+	insertID       func() string
+	errReporting   bool
+	projectID      string
+	severityMap    SeverityMapper
+	sampler        *sampler
+	redactor       Redactor
+
+	// nested are the fields given to With from its first namespace on, which
+	// are added after the metadata of the entries so that the metadata is
+	// left at their root.
+	nested []zapcore.Field
+}
+
+// entryAttributes sets the level and labels of the entry it's logged with,
+// without adding anything to its payload.
+type entryAttributes struct {
+	entryLevel  zapcore.Level
+	entryLabels map[string]string
+}
+
+func (a *entryAttributes) level(zapcore.Level) zapcore.Level {
+	return a.entryLevel
+}
+
+func (a *entryAttributes) labels() map[string]string {
+	return a.entryLabels
+}
+
+func (a *entryAttributes) field() zapcore.Field {
+	return zapcore.Field{Type: zapcore.SkipType, Interface: a}
+}
+
+// Option configures a Core built by NewCore.
+type Option func(*Core)
+
+// NewCore returns a Core wrapping core, configured by opts. It panics if core
+// is nil, rather than when the first entry is written.
+func NewCore(core zapcore.Core, opts ...Option) *Core {
+	if core == nil {
+		panic("stackdriver: NewCore called with a nil inner core")
+	}
+
+	c := &Core{
+		Core: core,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// NewJSONCore returns a Core writing entries to ws as JSON, with the levels
+// enabled by enab, configured by opts:
+//
+//	core := stackdriver.NewJSONCore(os.Stdout, zapcore.InfoLevel,
+//		stackdriver.WithReportLocation(true),
+//		stackdriver.WithServiceContext("foo", "1.2.3"))
+//
+// enab can be a zap.AtomicLevel, such as one from LevelFromEnv, to change the
+// levels enabled at runtime.
+func NewJSONCore(ws zapcore.WriteSyncer, enab zapcore.LevelEnabler, opts ...Option) *Core {
+	return NewCore(zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), ws, enab), opts...)
+}
+
+// WrapCore wraps the core of a logger in a Core configured by opts:
+//
+//	logger := zap.New(inner, stackdriver.WrapCore(stackdriver.WithReportLocation(true)))
+func WrapCore(opts ...Option) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return NewCore(core, opts...)
+	})
 }
 
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 	fields, ctx := c.extractCtx(fields)
+	fields = c.redactFields(fields)
 
-	return &Core{
-		Core:              c.Core.With(fields),
-		SetReportLocation: c.SetReportLocation,
-		ctx:               ctx,
+	if c.BoolsAsStrings {
+		fields = boolsAsStrings(fields)
 	}
+
+	clone := *c
+	clone.ctx = ctx
+
+	if i := namespaceIndex(fields); len(c.nested) > 0 || i < len(fields) {
+		if len(c.nested) > 0 {
+			i = 0
+		}
+
+		clone.nested = append(c.nested[:len(c.nested):len(c.nested)], fields[i:]...)
+		fields = fields[:i]
+	}
+
+	clone.Core = c.Core.With(fields)
+
+	return &clone
+}
+
+// Enabled reports whether entries at lv are written. DEBUG entries of a
+// logger with a sampled trace are always written when WithSampledDebug is
+// used.
+func (c *Core) Enabled(lv zapcore.Level) bool {
+	if c.sampledDebug && lv >= zapcore.DebugLevel && c.ctx != nil && c.ctx.trace != "" && c.ctx.traceSampled {
+		return true
+	}
+
+	return c.Core.Enabled(lv)
 }
 
 func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if c.Enabled(entry.Level) {
+	if c.Enabled(entry.Level) && c.sampler.keep(entry) {
 		return ce.AddCore(entry, c)
 	}
 
@@ -70,119 +305,490 @@ func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Che
 }
 
 func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Level > zapcore.ErrorLevel && entry.Level <= zapcore.FatalLevel {
+		// zap may panic or exit as soon as the entry is written, so it's
+		// flushed first, whatever the inner core does and whatever level the
+		// entry is written with.
+		defer c.Core.Sync()
+	}
+
 	loc := c.getReportLocationFromEntry(entry)
 
 	if loc != nil {
 		fields = append(fields, LogReportLocation(loc))
 	}
 
+	if c.uptime {
+		fields = append(fields, zap.Duration("uptime", time.Since(processStart)))
+	}
+
+	if c.remapDPanic && entry.Level == zapcore.DPanicLevel {
+		entry.Level = c.dpanicLevel
+	}
+
+	entry.Level = fieldsLevel(entry.Level, fields)
+
+	if !c.levelEnabled(entry.Level) {
+		return nil
+	}
+
+	if severity := c.mapSeverity(entry.Level); severity != "" {
+		entry.Level = levelOfSeverity(severity)
+	}
+
 	fields, ctx := c.extractCtx(fields)
-	fields = append(fields, zap.Object("context", ctx))
+	fields = c.redactFields(fields)
+	c.redactContext(ctx)
+
+	if c.SetSourceLocation {
+		// Entries logged without a caller can still have a report location
+		// set explicitly, which is then the best guess of their source.
+		if ctx.sourceLocation = entryLocation(entry); ctx.sourceLocation == nil {
+			ctx.sourceLocation = ctx.ReportLocation
+		}
+	}
+
+	if c.stackHash && entry.Stack != "" {
+		ctx.setLabel(labelStackHash, stackHash(entry.Stack, c.stackFrames))
+	}
+
+	if c.samplingPrio && ctx.trace != "" {
+		ctx.setLabel(labelSamplingPriority, samplingPriority(ctx.traceSampled))
+	}
+
+	if ctx.errorGroup != "" {
+		ctx.ReportLocation = &ReportLocation{FunctionName: ctx.errorGroup}
+		ctx.setLabel(labelErrorGroup, ctx.errorGroup)
+	}
+
+	if c.BoolsAsStrings {
+		fields = boolsAsStrings(fields)
+	}
 
-	entry.Message = c.appendFields(entry.Message, fields)
+	if c.MessageFields && !c.AutoPayload {
+		entry.Message = c.appendFields(entry.Message, fields)
+	}
+
+	n := len(fields)
+
+	if !c.AutoPayload || !ctx.isEmpty() {
+		fields = append(fields, zap.Object("context", ctx))
+	}
+
+	fields = c.appendMetadata(fields, ctx)
+
+	if isErrorLevel(entry.Level) && (entry.Stack != "" || ctx.ReportLocation != nil) {
+		fields = append(fields, zap.String(logKeyType, typeReportedErrorEvent))
+	}
+
+	if c.errReporting && isErrorLevel(entry.Level) && entry.Stack != "" {
+		entry.Message += "\n\n" + goroutineStack(entry.Stack)
+		entry.Stack = ""
+	}
+
+	return c.Core.Write(entry, c.nest(fields, n))
+}
+
+// goroutineStack converts a stack formatted by zap, whose frames are a
+// function followed by an indented file and line, to the format of
+// runtime.Stack, which Error Reporting parses.
+func goroutineStack(stack string) string {
+	b := strings.Builder{}
+	b.WriteString("goroutine 1 [running]:")
+
+	for _, line := range strings.Split(stack, "\n") {
+		b.WriteByte('\n')
+		b.WriteString(line)
+
+		if line != "" && !strings.HasPrefix(line, "\t") {
+			b.WriteString("(...)")
+		}
+	}
 
-	return c.Core.Write(entry, fields)
+	return b.String()
+}
+
+// isErrorLevel reports whether lv is ERROR or above, ignoring the severities
+// outside of zap's range.
+func isErrorLevel(lv zapcore.Level) bool {
+	return lv >= zapcore.ErrorLevel && lv <= zapcore.FatalLevel
+}
+
+func (c *Core) levelEnabled(lv zapcore.Level) bool {
+	if lv == noticeLevel {
+		lv = zapcore.InfoLevel
+	}
+
+	return c.Enabled(lv)
+}
+
+// appendMetadata adds the fields which Cloud Logging reads from the root of
+// the entry rather than from its payload.
+func (c *Core) appendMetadata(fields []zapcore.Field, ctx *Context) []zapcore.Field {
+	if ctx.HTTPRequest != nil {
+		fields = append(fields, zap.Object(logKeyHTTPRequest, logEntryHTTPRequest{ctx.HTTPRequest}))
+	}
+
+	if len(ctx.labels) > 0 {
+		fields = append(fields, zap.Object(logKeyLabels, c.truncateLabels(ctx.labels)))
+	}
+
+	if ctx.operation != nil {
+		fields = append(fields, zap.Object(logKeyOperation, ctx.operation))
+	}
+
+	if ctx.sourceLocation != nil {
+		fields = append(fields, zap.Object(logKeySourceLocation, sourceLocation{ctx.sourceLocation}))
+	}
+
+	if ctx.trace != "" {
+		fields = append(fields, zap.String(logKeyTrace, c.traceName(ctx.trace)))
+
+		if ctx.spanID != "" {
+			fields = append(fields, zap.String(logKeySpanID, ctx.spanID))
+		}
+
+		if ctx.traceSampled {
+			fields = append(fields, zap.Bool(logKeyTraceSampled, true))
+		}
+	} else if ctx.spanID != "" {
+		c.reportError("dropped span ID %q of an entry without a trace", ctx.spanID)
+	}
+
+	if c.serviceContext != nil {
+		fields = append(fields, LogServiceContext(c.versioned(c.serviceContext)))
+	}
+
+	if id := ctx.insertID; id != "" {
+		fields = append(fields, zap.String(logKeyInsertID, id))
+	} else if c.insertID != nil {
+		fields = append(fields, zap.String(logKeyInsertID, c.insertID()))
+	}
+
+	return fields
+}
+
+// versioned returns sc with the version set by WithVersionFromBuildInfo if it
+// has none.
+func (c *Core) versioned(sc *ServiceContext) *ServiceContext {
+	if sc.Version != "" || c.version == "" {
+		return sc
+	}
+
+	sc = sc.Clone()
+	sc.Version = c.version
+	return sc
 }
 
 func (c *Core) Sync() error {
 	return c.Core.Sync()
 }
 
-func (c *Core) appendFields(str string, fields []zapcore.Field) string {
-	builder := strings.Builder{}
-	builder.WriteString(str)
+func (c *Core) reportError(format string, args ...interface{}) {
+	if c.ErrorOutput == nil {
+		return
+	}
+
+	fmt.Fprintf(c.ErrorOutput, "%v stackdriver: %s\n", time.Now(), fmt.Sprintf(format, args...))
+	c.ErrorOutput.Sync()
+}
+
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+func (c *Core) appendFields(str string, fields []zapcore.Field) (out string) {
+	bufp := messagePool.Get().(*[]byte)
+	buf := append((*bufp)[:0], str...)
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.reportError("recovered from a panic appending fields to the message: %v", r)
+		}
+
+		out = string(buf)
+		*bufp = buf
+		messagePool.Put(bufp)
+	}()
+
+	prefix := c.namespacePrefix()
+
 	for _, field := range fields {
-		if field.Key == "context" {
+		if field.Type == zapcore.NamespaceType {
+			prefix += field.Key + "."
+			continue
+		}
+
+		if field.Key == "context" || field.Type == zapcore.SkipType {
 			continue
 		}
-		builder.WriteString(" ")
-		builder.WriteString(field.Key)
-		builder.WriteString("=")
-		builder.WriteString(fmt.Sprintf("%v", c.fieldValueToString(field)))
+		buf = append(buf, ' ')
+		buf = append(buf, prefix...)
+		buf = append(buf, field.Key...)
+		buf = append(buf, '=')
+		buf = c.appendFieldValue(buf, field)
 	}
 
-	return builder.String()
+	return
 }
 
-func (c *Core) fieldValueToString(field zapcore.Field) string {
+func (c *Core) fieldValueToString(field zapcore.Field) (out string) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Println("Recovered fieldValueToString:", r)
+			c.reportError("recovered from a panic formatting field %q: %v", field.Key, r)
 		}
 	}()
 
+	return string(c.appendFieldValue(nil, field))
+}
+
+func (c *Core) appendFieldValue(buf []byte, field zapcore.Field) []byte {
+	if fn, ok := lookupFieldFormatter(field); ok {
+		return append(buf, fn(field)...)
+	}
+
 	switch field.Type {
-	case zapcore.ArrayMarshalerType:
-		return ""
-	case zapcore.ObjectMarshalerType:
-		return ""
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		return appendMarshaler(buf, field)
 	case zapcore.BinaryType:
-		return ""
+		return appendBase64(buf, field.Interface.([]byte))
 	case zapcore.BoolType:
-		return strconv.FormatInt(field.Integer, 10)
+		return strconv.AppendBool(buf, field.Integer == 1)
 	case zapcore.ByteStringType:
-		return ""
+		return append(buf, field.Interface.([]byte)...)
 	case zapcore.Complex128Type:
-		return ""
+		return append(buf, fmt.Sprint(field.Interface.(complex128))...)
 	case zapcore.Complex64Type:
-		return ""
+		return append(buf, fmt.Sprint(field.Interface.(complex64))...)
 	case zapcore.DurationType:
-		return strconv.FormatInt(field.Integer / 1000000, 10)
+		return strconv.AppendInt(buf, field.Integer/1000000, 10)
 	case zapcore.Float64Type:
-		return strconv.FormatFloat(math.Float64frombits(uint64(field.Integer)), 'e', 2, 64)
+		return strconv.AppendFloat(buf, math.Float64frombits(uint64(field.Integer)), 'g', -1, 64)
 	case zapcore.Float32Type:
-		return strconv.FormatFloat(float64(math.Float32frombits(uint32(field.Integer))), 'e', 2, 32)
-	case zapcore.Int64Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Int32Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Int16Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Int8Type:
-		return strconv.FormatInt(field.Integer, 10)
+		return strconv.AppendFloat(buf, float64(math.Float32frombits(uint32(field.Integer))), 'g', -1, 32)
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.AppendInt(buf, field.Integer, 10)
 	case zapcore.StringType:
-		return field.String
+		return append(buf, field.String...)
 	case zapcore.TimeType:
-		return time.Unix(0, field.Integer).String()
+		t := time.Unix(0, field.Integer)
+
+		if loc, ok := field.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+
+		return append(buf, t.String()...)
 	case zapcore.TimeFullType:
-		return field.Interface.(time.Time).String()
-	case zapcore.Uint64Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Uint32Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Uint16Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.Uint8Type:
-		return strconv.FormatInt(field.Integer, 10)
-	case zapcore.UintptrType:
-		return strconv.FormatInt(field.Integer, 10)
+		return append(buf, field.Interface.(time.Time).String()...)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return strconv.AppendUint(buf, uint64(field.Integer), 10)
 	case zapcore.ReflectType:
-		return ""
-	case zapcore.NamespaceType:
-		return ""
+		return appendReflected(buf, field.Interface)
 	case zapcore.StringerType:
-		return field.Interface.(fmt.Stringer).String()
+		return append(buf, field.Interface.(fmt.Stringer).String()...)
 	case zapcore.ErrorType:
-		return field.Interface.(error).Error()
-	case zapcore.SkipType:
-		return ""
+		return append(buf, field.Interface.(error).Error()...)
 	}
-	return ""
+
+	return buf
+}
+
+// appendMarshaler appends the object or array of field compactly, such as
+// {id:7,name:alice} or [a b c]. If its marshaler panics, <panic: value> is
+// appended instead.
+func appendMarshaler(buf []byte, field zapcore.Field) (out []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = append(buf, fmt.Sprintf("<panic: %v>", r)...)
+		}
+	}()
+
+	enc := zapcore.NewMapObjectEncoder()
+
+	if field.Type == zapcore.ArrayMarshalerType {
+		enc.AddArray(field.Key, field.Interface.(zapcore.ArrayMarshaler))
+	} else {
+		enc.AddObject(field.Key, field.Interface.(zapcore.ObjectMarshaler))
+	}
+
+	return appendMarshaledValue(buf, enc.Fields[field.Key])
+}
+
+func appendMarshaledValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		buf = append(buf, '{')
+
+		for i, k := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, k...)
+			buf = append(buf, ':')
+			buf = appendMarshaledValue(buf, v[k])
+		}
+
+		return append(buf, '}')
+	case []interface{}:
+		buf = append(buf, '[')
+
+		for i, elem := range v {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = appendMarshaledValue(buf, elem)
+		}
+
+		return append(buf, ']')
+	case string:
+		return append(buf, v...)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float64:
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	case time.Duration:
+		return strconv.AppendInt(buf, int64(v/time.Millisecond), 10)
+	case []byte:
+		return appendBase64(buf, v)
+	case time.Time:
+		return append(buf, v.String()...)
+	case error:
+		return append(buf, v.Error()...)
+	case fmt.Stringer:
+		return append(buf, v.String()...)
+	}
+
+	return appendReflected(buf, v)
+}
+
+// appendBase64 appends b encoded in base64, as it is in the JSON payload.
+func appendBase64(buf, b []byte) []byte {
+	n := len(buf)
+	buf = append(buf, make([]byte, base64.StdEncoding.EncodedLen(len(b)))...)
+	base64.StdEncoding.Encode(buf[n:], b)
+	return buf
+}
+
+// appendReflected appends v encoded in JSON, as it is in the JSON payload, or
+// formatted with fmt.Sprint if it can't be, such as complex numbers.
+func appendReflected(buf []byte, v interface{}) []byte {
+	if b, err := json.Marshal(v); err == nil {
+		return append(buf, b...)
+	}
+
+	return append(buf, fmt.Sprint(v)...)
 }
 
 func (c *Core) extractCtx(fields []zapcore.Field) ([]zapcore.Field, *Context) {
 	output := []zapcore.Field{}
 	ctx := c.cloneCtx()
+	prefix := c.namespacePrefix()
 
 	for _, f := range fields {
-		switch f.Key {
+		if f.Type == zapcore.NamespaceType {
+			prefix += f.Key + "."
+		}
+
+		switch namespacedKey(prefix, f) {
 		case logKeyContextHTTPRequest:
-			ctx.HTTPRequest = f.Interface.(*HTTPRequest)
+			if req, ok := f.Interface.(*HTTPRequest); ok {
+				ctx.HTTPRequest = req
+			} else {
+				output = append(output, f)
+			}
 		case logKeyContextReportLocation:
-			ctx.ReportLocation = f.Interface.(*ReportLocation)
+			if loc, ok := f.Interface.(*ReportLocation); ok {
+				ctx.ReportLocation = loc
+			} else {
+				output = append(output, f)
+			}
 		case logKeyContextUser:
 			ctx.User = f.String
+		case logKeyOperation:
+			if op, ok := f.Interface.(*Operation); ok {
+				ctx.operation = op
+			} else {
+				output = append(output, f)
+			}
+		case logKeyTrace:
+			if span, ok := f.Interface.(*traceSpan); ok {
+				ctx.trace = span.trace
+				ctx.spanID = span.spanID
+				ctx.traceSampled = span.sampled
+			} else {
+				ctx.trace = f.String
+			}
+		case logKeySpanID:
+			ctx.spanID = f.String
+		case logKeyTraceSampled:
+			ctx.traceSampled = f.Integer == 1
+		case logKeyErrorGroup:
+			ctx.errorGroup = f.String
+		case logKeyInsertID:
+			ctx.insertID = f.String
+		case logKeyServiceContext:
+			if sc, ok := f.Interface.(*ServiceContext); ok {
+				f = LogServiceContext(c.versioned(sc))
+			}
+
+			output = append(output, f)
+		case logKeyLabels:
+			var labels map[string]string
+
+			switch m := f.Interface.(type) {
+			case stringMap:
+				labels = m
+			case map[string]string:
+				labels = m
+			default:
+				output = append(output, f)
+				continue
+			}
+
+			for k, v := range labels {
+				ctx.setLabel(k, v)
+			}
 		default:
+			if key, ok := f.Interface.(removedLabel); ok {
+				delete(ctx.labels, string(key))
+				continue
+			}
+
+			if bound, ok := f.Interface.(boundContext); ok {
+				c.correlate(ctx, bound)
+				continue
+			}
+
+			if l, ok := f.Interface.(labeler); ok {
+				for k, v := range l.labels() {
+					ctx.setLabel(k, v)
+				}
+			}
+
+			if l, ok := f.Interface.(locator); ok {
+				if loc := l.reportLocation(); loc != nil {
+					ctx.ReportLocation = loc
+				}
+			}
+
+			if o, ok := f.Interface.(operator); ok {
+				ctx.operation = o.operation()
+			}
+
 			output = append(output, f)
 		}
 	}
@@ -190,6 +796,48 @@ func (c *Core) extractCtx(fields []zapcore.Field) ([]zapcore.Field, *Context) {
 	return output, ctx
 }
 
+func (c *Core) truncateLabels(src map[string]string) stringMap {
+	max := c.MaxLabelValueLength
+
+	if max <= 0 {
+		max = defaultMaxLabelValueLength
+	}
+
+	for k, v := range src {
+		if len(v) > max {
+			src[k] = truncateString(v, max)
+		}
+	}
+
+	return stringMap(src)
+}
+
+// truncateString shortens s to at most max bytes, including the truncation
+// marker, without splitting a UTF-8 sequence.
+func truncateString(s string, max int) string {
+	n := max - len(labelTruncatedMarker)
+
+	if n < 0 {
+		return labelTruncatedMarker[:max]
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n] + labelTruncatedMarker
+}
+
+// traceName returns the resource name of trace, which is formatted with the
+// project set by WithProjectID when it's a bare trace ID.
+func (c *Core) traceName(trace string) string {
+	if c.projectID == "" || !isValidTraceID(trace) {
+		return trace
+	}
+
+	return "projects/" + c.projectID + "/traces/" + strings.ToLower(trace)
+}
+
 func (c *Core) cloneCtx() *Context {
 	if c.ctx == nil {
 		return &Context{}
@@ -203,6 +851,10 @@ func (c *Core) getReportLocationFromEntry(entry zapcore.Entry) *ReportLocation {
 		return nil
 	}
 
+	return entryLocation(entry)
+}
+
+func entryLocation(entry zapcore.Entry) *ReportLocation {
 	caller := entry.Caller
 
 	if !caller.Defined {
@@ -221,10 +873,34 @@ func (c *Core) getReportLocationFromEntry(entry zapcore.Entry) *ReportLocation {
 	return loc
 }
 
+// callerLocation returns the location of the caller skip frames above the
+// caller of callerLocation, like runtime.Caller does.
+func callerLocation(skip int) *ReportLocation {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+
+	if !ok {
+		return nil
+	}
+
+	loc := &ReportLocation{
+		FilePath:   file,
+		LineNumber: line,
+	}
+
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		loc.FunctionName = fn.Name()
+	}
+
+	return loc
+}
+
 func LogServiceContext(ctx *ServiceContext) zapcore.Field {
 	return zap.Object(logKeyServiceContext, ctx)
 }
 
+// LogHTTPRequest sets the HTTP request of the entry, which is written both in
+// its context for Error Reporting and as its httpRequest for the request
+// viewer of Cloud Logging.
 func LogHTTPRequest(req *HTTPRequest) zapcore.Field {
 	return zap.Object(logKeyContextHTTPRequest, req)
 }
@@ -233,10 +909,113 @@ func LogUser(user string) zapcore.Field {
 	return zap.String(logKeyContextUser, user)
 }
 
+// LogLabels adds labels to the entry, or to every entry of a child logger,
+// which Cloud Logging indexes for filtering. They are merged with the labels
+// of the parent logger, replacing the ones with the same key.
+func LogLabels(labels map[string]string) zapcore.Field {
+	return zap.Object(logKeyLabels, stringMap(labels))
+}
+
+type removedLabel string
+
+// RemoveLabel removes a label inherited from the parent logger. The parent
+// keeps it.
+func RemoveLabel(key string) zapcore.Field {
+	return zapcore.Field{Type: zapcore.SkipType, Interface: removedLabel(key)}
+}
+
+// LogInsertID sets the insertId of the entry, which Cloud Logging deduplicates
+// the entries with the same insertId and timestamp by, such as to write an
+// entry again without it being logged twice. It takes precedence over the
+// insertId set by WithInsertID.
+func LogInsertID(id string) zapcore.Field {
+	return zap.String(logKeyInsertID, id)
+}
+
+// LogErrorGroup makes Error Reporting group the entry with every other entry
+// logged with the same fingerprint, regardless of their messages. The
+// fingerprint replaces the report location, which Error Reporting groups
+// entries without a stack trace by, and is added as the errorGroup label.
+func LogErrorGroup(fingerprint string) zapcore.Field {
+	return zap.String(logKeyErrorGroup, fingerprint)
+}
+
 func LogReportLocation(loc *ReportLocation) zapcore.Field {
 	return zap.Object(logKeyContextReportLocation, loc)
 }
 
+// mapSeverity returns the severity set for lv by WithSeverityMapper or, failing
+// that, LevelSeverity. It returns an empty string when lv keeps its severity.
+func (c *Core) mapSeverity(lv zapcore.Level) string {
+	if c.severityMap != nil {
+		if severity := c.severityMap(lv); severity != "" {
+			return severity
+		}
+	}
+
+	return c.LevelSeverity[lv]
+}
+
+// levelOfSeverity returns the level EncodeLevel writes as severity.
+func levelOfSeverity(severity string) zapcore.Level {
+	if lv, ok := severityLevel[severity]; ok {
+		return lv
+	}
+
+	return defaultLevel
+}
+
+// EncodeLevel writes the severity of lv, or DEFAULT for levels without one.
 func EncodeLevel(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
-	enc.AppendString(logLevelSeverity[lv])
+	enc.AppendString(Severity(lv))
+}
+
+// Severity returns the severity of lv written by EncodeLevel, for writers
+// which don't go through an encoder.
+func Severity(lv zapcore.Level) string {
+	return levelSeverity(logLevelSeverity, lv)
+}
+
+// NearestSeverity is a SeverityMapper for custom levels, which are written
+// with the severity of the nearest level of zap: those below DebugLevel as
+// DEBUG and those above FatalLevel as EMERGENCY. It keeps the severity of the
+// levels of zap and of NOTICE entries.
+func NearestSeverity(lv zapcore.Level) string {
+	switch {
+	case lv < zapcore.DebugLevel:
+		return logLevelSeverity[zapcore.DebugLevel]
+	case lv > zapcore.FatalLevel && lv != noticeLevel && lv != defaultLevel:
+		return logLevelSeverity[zapcore.FatalLevel]
+	}
+
+	return ""
+}
+
+func levelSeverity(severities map[zapcore.Level]string, lv zapcore.Level) string {
+	if s, ok := severities[lv]; ok {
+		return s
+	}
+
+	return severityDefault
+}
+
+// NewLevelEncoder returns a level encoder which writes the severities in
+// severities instead of the default ones, which are used for missing levels.
+// Levels without any severity are written as DEFAULT.
+// The map is copied, so it can't be changed once the encoder is built and is
+// safe to read concurrently.
+func NewLevelEncoder(severities map[zapcore.Level]string) zapcore.LevelEncoder {
+	m := make(map[zapcore.Level]string, len(logLevelSeverity)+len(severities))
+
+	for lv, s := range logLevelSeverity {
+		m[lv] = s
+	}
+
+	for lv, s := range severities {
+		m[lv] = s
+	}
+
+	return func(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(levelSeverity(m, lv))
+	}
 }
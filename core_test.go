@@ -2,11 +2,15 @@ package stackdriver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"math"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,17 +21,22 @@ import (
 )
 
 type logEntry struct {
-	Severity       string          `json:"severity"`
-	EventTime      logEntryTime    `json:"timestamp"`
-	ServiceContext *ServiceContext `json:"serviceContext"`
-	Message        string          `json:"message"`
-	Context        *Context        `json:"context"`
+	Severity       string            `json:"severity"`
+	EventTime      logEntryTime      `json:"timestamp"`
+	ServiceContext *ServiceContext   `json:"serviceContext"`
+	Message        string            `json:"message"`
+	Context        *Context          `json:"context"`
+	Labels         map[string]string `json:"logging.googleapis.com/labels"`
+	Operation      *Operation        `json:"logging.googleapis.com/operation"`
+	Trace          string            `json:"logging.googleapis.com/trace"`
+	SpanID         string            `json:"logging.googleapis.com/spanId"`
+	TraceSampled   bool              `json:"logging.googleapis.com/trace_sampled"`
 }
 
 type logEntryTime time.Time
 
 func (t *logEntryTime) UnmarshalText(text []byte) error {
-	res, err := time.Parse("2006-01-02T15:04:05.000Z0700", string(text))
+	res, err := time.Parse(time.RFC3339Nano, string(text))
 
 	if err != nil {
 		return err
@@ -46,11 +55,114 @@ func newCore(writer io.Writer) *Core {
 	}
 }
 
+func TestNewCore(t *testing.T) {
+	inner := zapcore.NewNopCore()
+	core := NewCore(inner, func(c *Core) {
+		c.SetReportLocation = true
+	})
+
+	assert.Equal(t, &Core{
+		Core:              inner,
+		SetReportLocation: true,
+	}, core)
+
+	assert.PanicsWithValue(t, "stackdriver: NewCore called with a nil inner core", func() {
+		NewCore(nil)
+	})
+}
+
+func TestNewJSONCore(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(NewJSONCore(zapcore.AddSync(writer), zapcore.InfoLevel,
+		WithServiceContext("foo", "1.2.3"),
+		WithLabels(map[string]string{"team": "bar"}),
+	))
+
+	logger.Debug("dropped")
+	logger.Info("baz")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "INFO", actual.Severity)
+	assert.Equal(t, "baz", actual.Message)
+	assert.Equal(t, &ServiceContext{Service: "foo", Version: "1.2.3"}, actual.ServiceContext)
+	assert.Equal(t, map[string]string{"team": "bar"}, actual.Labels)
+}
+
+func TestWrapCore(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+	inner := zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.DebugLevel)
+
+	t.Run("New", func(t *testing.T) {
+		defer writer.Reset()
+
+		zap.New(inner, WrapCore()).Info("foo", zap.String("bar", "baz"))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "foo", actual.Message)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger := zap.New(inner, zap.AddCaller()).WithOptions(WrapCore(
+			WithReportLocation(true),
+			WithLevelSeverity(map[zapcore.Level]string{zapcore.WarnLevel: "NOTICE"}),
+		))
+		logger.Warn("foo")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "NOTICE", actual.Severity)
+		require.NotNil(t, actual.Context.ReportLocation)
+		assert.Contains(t, actual.Context.ReportLocation.FunctionName, "TestWrapCore")
+	})
+}
+
 func TestCore(t *testing.T) {
 	writer := bytes.NewBuffer(nil)
 	core := newCore(writer)
 	logger := zap.New(core)
 
+	t.Run("Message fields", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.MessageFields = true
+		zap.New(core).With(LogUser("baz")).Info("test", zap.String("foo", "bar"), zap.Int("qux", 1))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "test foo=bar qux=1", actual.Message)
+		assert.Equal(t, &Context{User: "baz"}, actual.Context)
+	})
+
+	t.Run("Structured payload", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.With(LogUser("baz")).Info("test",
+			zap.String("foo", "bar"),
+			zap.Int("qux", 1),
+			zap.Object("obj", zapcore.ObjectMarshalerFunc(func(e zapcore.ObjectEncoder) error {
+				e.AddBool("ok", true)
+				return nil
+			})),
+			LogServiceContext(&ServiceContext{Service: "svc"}),
+		)
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "test", actual["message"])
+		assert.Equal(t, "bar", actual["foo"])
+		assert.Equal(t, float64(1), actual["qux"])
+		assert.Equal(t, map[string]interface{}{"ok": true}, actual["obj"])
+		assert.Equal(t, map[string]interface{}{"user": "baz"}, actual["context"])
+		assert.Equal(t, map[string]interface{}{"service": "svc", "version": ""}, actual["serviceContext"])
+		assert.NotContains(t, actual, logKeyContextUser)
+	})
+
 	t.Run("Basic", func(t *testing.T) {
 		defer writer.Reset()
 
@@ -68,7 +180,7 @@ func TestCore(t *testing.T) {
 
 		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
 		assert.Equal(t, "DEBUG", actual.Severity)
-		assert.Equal(t, "test foo=bar", actual.Message)
+		assert.Equal(t, "test", actual.Message)
 		assert.WithinDuration(t, time.Now(), time.Time(actual.EventTime), time.Second)
 		assert.Equal(t, &ServiceContext{
 			Service: "foo",
@@ -110,6 +222,160 @@ func TestCore(t *testing.T) {
 		}, actual.Context)
 	})
 
+	t.Run("Auto payload without fields", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.AutoPayload = true
+		zap.New(core).Info("test")
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "test", actual["message"])
+		assert.NotContains(t, actual, "context")
+		assert.Len(t, actual, 3)
+	})
+
+	t.Run("Auto payload with fields", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.AutoPayload = true
+		zap.New(core).Info("test", zap.String("foo", "bar"), LogUser("baz"))
+
+		var actual struct {
+			logEntry
+
+			Foo string `json:"foo"`
+		}
+
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "test", actual.Message)
+		assert.Equal(t, "bar", actual.Foo)
+		assert.Equal(t, &Context{User: "baz"}, actual.Context)
+	})
+
+	t.Run("Truncate oversized label values", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.MaxLabelValueLength = 19
+		logger := zap.New(core).With(LogLabels(map[string]string{
+			"short": "foo",
+			"long":  strings.Repeat("é", 20),
+		}))
+		logger.Info("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "foo", actual.Labels["short"])
+		assert.Equal(t, "éé"+labelTruncatedMarker, actual.Labels["long"])
+		assert.LessOrEqual(t, len(actual.Labels["long"]), 19)
+	})
+
+	t.Run("Error group", func(t *testing.T) {
+		defer writer.Reset()
+
+		core := newCore(writer)
+		core.SetReportLocation = true
+		logger := zap.New(core, zap.AddCaller()).With(LogErrorGroup("db-timeout"))
+		logger.Error("query 1 timed out")
+		logger.Error("query 2 timed out")
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var first, second logEntry
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &first))
+		require.Nil(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.NotEqual(t, first.Message, second.Message)
+		assert.Equal(t, &ReportLocation{FunctionName: "db-timeout"}, first.Context.ReportLocation)
+		assert.Equal(t, first.Context, second.Context)
+		assert.Equal(t, map[string]string{"errorGroup": "db-timeout"}, first.Labels)
+		assert.Equal(t, first.Labels, second.Labels)
+	})
+
+	t.Run("Merge labels", func(t *testing.T) {
+		defer writer.Reset()
+
+		parent := zap.New(newCore(writer)).With(LogLabels(map[string]string{
+			"env":     "prod",
+			"version": "1.4.2",
+		}))
+		child := parent.With(LogLabels(map[string]string{
+			"version": "1.4.3",
+			"region":  "eu",
+		}))
+		child.Info("", LogLabels(map[string]string{"request": "42"}))
+		parent.Info("")
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var fromChild, fromParent logEntry
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &fromChild))
+		require.Nil(t, json.Unmarshal([]byte(lines[1]), &fromParent))
+		assert.Equal(t, map[string]string{
+			"env":     "prod",
+			"version": "1.4.3",
+			"region":  "eu",
+			"request": "42",
+		}, fromChild.Labels)
+		assert.Equal(t, map[string]string{
+			"env":     "prod",
+			"version": "1.4.2",
+		}, fromParent.Labels)
+		assert.NotContains(t, lines[0], `"context":{"labels"`)
+	})
+
+	t.Run("Remove inherited label", func(t *testing.T) {
+		defer writer.Reset()
+
+		parent := zap.New(newCore(writer)).With(LogLabels(map[string]string{
+			"foo": "1",
+			"bar": "2",
+		}))
+		parent.With(RemoveLabel("foo")).Info("")
+		parent.Info("")
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var child, actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &child))
+		require.Nil(t, json.Unmarshal([]byte(lines[1]), &actual))
+		assert.Equal(t, map[string]string{"bar": "2"}, child.Labels)
+		assert.Equal(t, map[string]string{"foo": "1", "bar": "2"}, actual.Labels)
+	})
+
+	t.Run("Sorted labels", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Info("", LogLabels(map[string]string{
+			"c": "3",
+			"a": "1",
+			"d": "4",
+			"b": "2",
+		}))
+
+		assert.Contains(t, writer.String(), `"logging.googleapis.com/labels":{"a":"1","b":"2","c":"3","d":"4"}`)
+	})
+
+	t.Run("Raw labels key", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Info("", zap.Any(logKeyLabels, map[string]string{"a": "b"}))
+		logger.Info("", zap.String(logKeyLabels, "x"))
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &actual))
+		assert.Equal(t, map[string]string{"a": "b"}, actual.Labels)
+		assert.Contains(t, lines[1], `"logging.googleapis.com/labels":"x"`)
+	})
+
 	t.Run("Set report location from entry", func(t *testing.T) {
 		defer writer.Reset()
 
@@ -129,6 +395,174 @@ func TestCore(t *testing.T) {
 	})
 }
 
+// recordingCore records the calls made to it, in order.
+type recordingCore struct {
+	zapcore.LevelEnabler
+
+	calls []string
+}
+
+func (c *recordingCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *recordingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(entry, c)
+}
+
+func (c *recordingCore) Write(entry zapcore.Entry, _ []zapcore.Field) error {
+	c.calls = append(c.calls, "write "+entry.Level.String())
+	return nil
+}
+
+func (c *recordingCore) Sync() error {
+	c.calls = append(c.calls, "sync")
+	return nil
+}
+
+func TestCoreSyncsBeforeExit(t *testing.T) {
+	inner := &recordingCore{LevelEnabler: zapcore.DebugLevel}
+	core := NewCore(inner)
+
+	write := func(lv zapcore.Level, fields ...zapcore.Field) (recovered interface{}) {
+		defer func() {
+			recovered = recover()
+		}()
+
+		entry := zapcore.Entry{Level: lv}
+
+		// Panicking after the write stands in for the exit of a fatal entry.
+		core.Check(entry, nil).Should(entry, zapcore.WriteThenPanic).Write(fields...)
+		return nil
+	}
+
+	assert.NotNil(t, write(zapcore.FatalLevel))
+	assert.Equal(t, []string{"write fatal", "sync"}, inner.calls)
+
+	inner.calls = nil
+	assert.NotNil(t, write(zapcore.FatalLevel, LogContextError(context.Canceled)))
+	assert.Equal(t, []string{"write info", "sync"}, inner.calls)
+
+	inner.calls = nil
+	assert.NotNil(t, write(zapcore.ErrorLevel))
+	assert.Equal(t, []string{"write error"}, inner.calls)
+
+	inner.calls = nil
+	assert.NotNil(t, write(noticeLevel))
+	assert.NotNil(t, write(defaultLevel))
+	assert.Equal(t, []string{"write Level(64)", "write Level(65)"}, inner.calls)
+}
+
+func TestFieldValueToString(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	core := newCore(bytes.NewBuffer(nil))
+
+	for _, tt := range []struct {
+		field    zapcore.Field
+		expected string
+	}{
+		{zap.Array("foo", zapcore.ArrayMarshalerFunc(func(zapcore.ArrayEncoder) error { return nil })), "[]"},
+		{zap.Object("foo", &ServiceContext{}), "{service:,version:}"},
+		{zap.Binary("foo", []byte{1, 2}), "AQI="},
+		{zap.Bool("foo", true), "true"},
+		{zap.Bool("foo", false), "false"},
+		{zap.ByteString("foo", []byte("bär")), "bär"},
+		{zap.Complex128("foo", complex(1, -2)), "(1-2i)"},
+		{zap.Complex64("foo", complex(1.5, 2)), "(1.5+2i)"},
+		{zap.Duration("foo", 1500*time.Millisecond), "1500"},
+		{zap.Float64("foo", 3.14), "3.14"},
+		{zap.Float64("foo", 1e21), "1e+21"},
+		{zap.Float32("foo", 3.14), "3.14"},
+		{zap.Int64("foo", -42), "-42"},
+		{zap.Int32("foo", -42), "-42"},
+		{zap.Int16("foo", -42), "-42"},
+		{zap.Int8("foo", -42), "-42"},
+		{zap.String("foo", "bar"), "bar"},
+		{zap.Time("foo", now), "2020-01-02 03:04:05 +0000 UTC"},
+		{zapcore.Field{Key: "foo", Type: zapcore.TimeFullType, Interface: now}, now.String()},
+		{zap.Uint64("foo", math.MaxUint64), "18446744073709551615"},
+		{zap.Uint32("foo", 42), "42"},
+		{zap.Uint16("foo", 42), "42"},
+		{zap.Uint8("foo", 42), "42"},
+		{zap.Uintptr("foo", 42), "42"},
+		{zap.Reflect("foo", []int{1, 2}), "[1,2]"},
+		{zap.Reflect("foo", map[string]int{"bar": 1}), `{"bar":1}`},
+		{zap.Reflect("foo", complex(1, 2)), "(1+2i)"},
+		{zap.Namespace("foo"), ""},
+		{zap.Stringer("foo", zapcore.WarnLevel), "warn"},
+		{zap.Error(errors.New("bar")), "bar"},
+		{zap.Skip(), ""},
+	} {
+		assert.Equal(t, tt.expected, core.fieldValueToString(tt.field), "field type %d", tt.field.Type)
+	}
+}
+
+func TestCore_ErrorReportingType(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := newCore(writer)
+	core.SetReportLocation = true
+
+	log := func(logger *zap.Logger, lv zapcore.Level) interface{} {
+		defer writer.Reset()
+
+		logger.Check(lv, "").Write()
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual["@type"]
+	}
+
+	withStack := zap.New(newCore(writer), zap.AddStacktrace(zapcore.ErrorLevel))
+	withLocation := zap.New(core, zap.AddCaller())
+	plain := zap.New(newCore(writer))
+	reportedErrorEvent := "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+	assert.Equal(t, reportedErrorEvent, log(withStack, zapcore.ErrorLevel))
+	assert.Equal(t, reportedErrorEvent, log(withLocation, zapcore.ErrorLevel))
+	assert.Equal(t, reportedErrorEvent, log(withLocation, zapcore.DPanicLevel))
+	assert.Nil(t, log(withLocation, zapcore.WarnLevel))
+	assert.Nil(t, log(plain, zapcore.ErrorLevel))
+	assert.Nil(t, log(withLocation, zapcore.InfoLevel))
+}
+
+func TestCore_LevelSeverity(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := newCore(writer)
+	core.LevelSeverity = map[zapcore.Level]string{
+		zapcore.WarnLevel:   "NOTICE",
+		zapcore.DPanicLevel: "ERROR",
+		zapcore.DebugLevel:  "FOO",
+	}
+	logger := zap.New(core).With(zap.String("foo", "bar"))
+
+	logger.Warn("")
+	logger.DPanic("")
+	logger.Debug("")
+	logger.Error("")
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 4)
+
+	var severities []string
+
+	for _, line := range lines {
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		severities = append(severities, actual.Severity)
+	}
+
+	assert.Equal(t, []string{"NOTICE", "ERROR", "DEFAULT", "ERROR"}, severities)
+}
+
+func TestEncoderConfig_Timestamp(t *testing.T) {
+	entry := zapcore.Entry{Time: time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)}
+	buf, err := zapcore.NewJSONEncoder(EncoderConfig).EncodeEntry(entry, nil)
+	require.Nil(t, err)
+	defer buf.Free()
+
+	assert.Contains(t, buf.String(), `"timestamp":"2020-01-02T03:04:05.123456789Z"`)
+}
+
 func TestLogServiceContext(t *testing.T) {
 	ctx := &ServiceContext{}
 	field := LogServiceContext(ctx)
@@ -146,6 +580,20 @@ func TestLogUser(t *testing.T) {
 	assert.Equal(t, zap.String(logKeyContextUser, "foo"), field)
 }
 
+func TestLogOperation(t *testing.T) {
+	field := LogOperation("foo", "bar", true, false)
+	assert.Equal(t, zap.Object(logKeyOperation, &Operation{
+		ID:       "foo",
+		Producer: "bar",
+		First:    true,
+	}), field)
+}
+
+func TestLogErrorGroup(t *testing.T) {
+	field := LogErrorGroup("foo")
+	assert.Equal(t, zap.String(logKeyErrorGroup, "foo"), field)
+}
+
 func TestLogReportLocation(t *testing.T) {
 	loc := &ReportLocation{}
 	field := LogReportLocation(loc)
@@ -185,6 +633,14 @@ func TestEncodeLevel(t *testing.T) {
 			Level:    zapcore.FatalLevel,
 			Expected: "EMERGENCY",
 		},
+		{
+			Level:    noticeLevel,
+			Expected: "NOTICE",
+		},
+		{
+			Level:    zapcore.Level(42),
+			Expected: "DEFAULT",
+		},
 	}
 
 	for _, test := range tests {
@@ -193,6 +649,150 @@ func TestEncodeLevel(t *testing.T) {
 			enc.On("AppendString", test.Expected).Once()
 			EncodeLevel(test.Level, enc)
 			enc.AssertExpectations(t)
+			assert.Equal(t, test.Expected, Severity(test.Level))
 		})
 	}
 }
+
+func TestNewLevelEncoder(t *testing.T) {
+	severities := map[zapcore.Level]string{
+		zapcore.WarnLevel: "NOTICE",
+	}
+
+	encodeFoo := NewLevelEncoder(severities)
+	encodeBar := NewLevelEncoder(map[zapcore.Level]string{
+		zapcore.WarnLevel: "ERROR",
+	})
+
+	// Changing the map afterwards must not affect the encoder.
+	severities[zapcore.WarnLevel] = "ALERT"
+
+	encode := func(encodeLevel zapcore.LevelEncoder, lv zapcore.Level) string {
+		config := EncoderConfig
+		config.EncodeLevel = encodeLevel
+		buf, err := zapcore.NewJSONEncoder(config).EncodeEntry(zapcore.Entry{Level: lv}, nil)
+		assert.Nil(t, err)
+		defer buf.Free()
+
+		var actual logEntry
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &actual))
+		return actual.Severity
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.Equal(t, "NOTICE", encode(encodeFoo, zapcore.WarnLevel))
+			assert.Equal(t, "ERROR", encode(encodeBar, zapcore.WarnLevel))
+			assert.Equal(t, "INFO", encode(encodeFoo, zapcore.InfoLevel))
+			assert.Equal(t, "WARNING", encode(EncodeLevel, zapcore.WarnLevel))
+			assert.Equal(t, "DEFAULT", encode(encodeFoo, zapcore.Level(42)))
+		}()
+	}
+
+	wg.Wait()
+}
+
+type panicStringer struct{}
+
+func (panicStringer) String() string {
+	panic("boom")
+}
+
+func TestAppendFieldsRecovers(t *testing.T) {
+	errOutput := bytes.NewBuffer(nil)
+	core := newCore(ioutil.Discard)
+	core.ErrorOutput = zapcore.AddSync(errOutput)
+
+	msg := core.appendFields("msg", []zapcore.Field{
+		zap.Int("a", 1),
+		zap.Stringer("b", panicStringer{}),
+		zap.Int("c", 3),
+	})
+
+	assert.Equal(t, "msg a=1 b=", msg)
+	assert.Contains(t, errOutput.String(), "stackdriver: recovered from a panic appending fields to the message: boom")
+
+	errOutput.Reset()
+	assert.Equal(t, "", core.fieldValueToString(zap.Stringer("b", panicStringer{})))
+	assert.Contains(t, errOutput.String(), `stackdriver: recovered from a panic formatting field "b": boom`)
+}
+
+func TestAppendFieldsMarshalers(t *testing.T) {
+	core := newCore(ioutil.Discard)
+	user := zapcore.ObjectMarshalerFunc(func(e zapcore.ObjectEncoder) error {
+		e.AddInt("id", 7)
+		e.AddString("name", "alice")
+		return e.AddArray("roles", zapcore.ArrayMarshalerFunc(func(a zapcore.ArrayEncoder) error {
+			a.AppendString("admin")
+			a.AppendString("dev")
+			return nil
+		}))
+	})
+	tags := zapcore.ArrayMarshalerFunc(func(a zapcore.ArrayEncoder) error {
+		a.AppendString("a")
+		a.AppendString("b")
+		a.AppendString("c")
+		return nil
+	})
+	broken := zapcore.ObjectMarshalerFunc(func(zapcore.ObjectEncoder) error {
+		panic("boom")
+	})
+
+	msg := core.appendFields("msg", []zapcore.Field{
+		zap.Object("user", user),
+		zap.Array("tags", tags),
+		zap.Object("broken", broken),
+		zap.Int("n", 1),
+	})
+
+	assert.Equal(t, "msg user={id:7,name:alice,roles:[admin dev]} tags=[a b c] broken=<panic: boom> n=1", msg)
+}
+
+func TestAppendFieldsMarshaledTypes(t *testing.T) {
+	core := newCore(ioutil.Discard)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	obj := zapcore.ObjectMarshalerFunc(func(e zapcore.ObjectEncoder) error {
+		e.AddBinary("binary", []byte{1, 2})
+		e.AddBool("bool", true)
+		e.AddByteString("byteString", []byte("bär"))
+		e.AddComplex128("complex", complex(1, -2))
+		e.AddDuration("duration", 1500*time.Millisecond)
+		e.AddFloat32("float", 0.5)
+		e.AddInt8("int", -42)
+		e.AddTime("time", now)
+		e.AddUint64("uint", math.MaxUint64)
+		return e.AddReflected("reflected", map[string]int{"bar": 1})
+	})
+
+	msg := core.appendFields("msg", []zapcore.Field{zap.Object("obj", obj)})
+
+	assert.Equal(t, "msg obj={binary:AQI=,bool:true,byteString:bär,complex:(1-2i),duration:1500,"+
+		`float:0.5,int:-42,reflected:{"bar":1},time:2020-01-02 03:04:05 +0000 UTC,uint:18446744073709551615}`, msg)
+}
+
+func BenchmarkAppendFields(b *testing.B) {
+	core := newCore(ioutil.Discard)
+	fields := []zapcore.Field{
+		zap.String("method", "GET"),
+		zap.Int("status", 200),
+		zap.Int64("bytes", 5123),
+		zap.Float64("ratio", 0.25),
+		zap.Bool("cached", true),
+		zap.Duration("latency", 15*time.Millisecond),
+		zap.Error(errors.New("upstream timeout")),
+		zap.Uint64("id", 1234567890),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		core.appendFields("request served", fields)
+	}
+}
@@ -0,0 +1,100 @@
+package stackdriver
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type correlation struct {
+	key   interface{}
+	label string
+}
+
+// WithCorrelationFromContext labels the entries of loggers bound to a
+// context.Context by ForContext with the value the context holds for key, if
+// any. The value is formatted with fmt.Sprint.
+func WithCorrelationFromContext(key interface{}, labelName string) Option {
+	return func(c *Core) {
+		c.correlations = append(c.correlations, correlation{
+			key:   key,
+			label: labelName,
+		})
+	}
+}
+
+const labelTenant = "tenant"
+
+// WithTenantFromContext labels the entries of loggers bound to a
+// context.Context by ForContext with the tenant the context holds for key.
+func WithTenantFromContext(key interface{}) Option {
+	return WithCorrelationFromContext(key, labelTenant)
+}
+
+// LogTenant labels the entry, or every entry of a child logger, with tenant.
+func LogTenant(tenant string) zapcore.Field {
+	return LogLabels(map[string]string{labelTenant: tenant})
+}
+
+type boundContext struct {
+	context.Context
+}
+
+// ForContext returns a child of logger bound to ctx, whose entries are
+// labelled with the values configured by WithCorrelationFromContext.
+func ForContext(logger *zap.Logger, ctx context.Context) *zap.Logger {
+	return logger.With(zapcore.Field{Type: zapcore.SkipType, Interface: boundContext{ctx}})
+}
+
+func (c *Core) correlate(ctx *Context, bound boundContext) {
+	for _, corr := range c.correlations {
+		if v := bound.Value(corr.key); v != nil {
+			ctx.setLabel(corr.label, fmt.Sprint(v))
+		}
+	}
+}
+
+type contextLoggerKey struct{}
+
+type contextLogger struct {
+	logger *zap.Logger
+	fields []zapcore.Field
+}
+
+func loggerFromContext(ctx context.Context) contextLogger {
+	cl, _ := ctx.Value(contextLoggerKey{}).(contextLogger)
+	return cl
+}
+
+// NewContext returns a copy of ctx holding fields, such as the trace, the user
+// or the HTTPRequest of a request, in addition to those it already holds. The
+// logger returned by FromContext adds them to all of its entries.
+func NewContext(ctx context.Context, fields ...zapcore.Field) context.Context {
+	cl := loggerFromContext(ctx)
+	cl.fields = append(cl.fields[:len(cl.fields):len(cl.fields)], fields...)
+	return context.WithValue(ctx, contextLoggerKey{}, cl)
+}
+
+// WithContext returns a copy of ctx holding logger, to be retrieved with
+// FromContext deeper in the call stack.
+func WithContext(ctx context.Context, logger *zap.Logger) context.Context {
+	cl := loggerFromContext(ctx)
+	cl.logger = logger
+	return context.WithValue(ctx, contextLoggerKey{}, cl)
+}
+
+// FromContext returns the logger held by ctx, or the global logger of zap if
+// it doesn't hold one, with the fields held by ctx. Like ForContext, it is
+// bound to ctx.
+func FromContext(ctx context.Context) *zap.Logger {
+	cl := loggerFromContext(ctx)
+	logger := cl.logger
+
+	if logger == nil {
+		logger = zap.L()
+	}
+
+	return ForContext(logger.With(cl.fields...), ctx)
+}
@@ -0,0 +1,106 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type correlationKey struct{}
+
+type tenantKey struct{}
+
+func TestWithCorrelationFromContext(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithCorrelationFromContext(correlationKey{}, "correlationId")))
+
+	log := func(logger *zap.Logger) map[string]string {
+		defer writer.Reset()
+
+		logger.Info("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Labels
+	}
+
+	ctx := context.WithValue(context.Background(), correlationKey{}, "abc123")
+	assert.Equal(t, map[string]string{"correlationId": "abc123"}, log(ForContext(logger, ctx)))
+	assert.Empty(t, log(ForContext(logger, context.Background())))
+	assert.Empty(t, log(logger))
+}
+
+func TestLogTenant(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+	core := NewCore(zapcore.NewCore(enc, zapcore.Lock(zapcore.AddSync(writer)), zapcore.DebugLevel), WithTenantFromContext(tenantKey{}))
+	logger := zap.New(core)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		tenant := fmt.Sprintf("tenant-%d", i)
+
+		go func() {
+			defer wg.Done()
+			logger.With(LogTenant(tenant)).With(zap.String("foo", "bar")).Info(tenant)
+		}()
+
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), tenantKey{}, tenant)
+			ForContext(logger, ctx).With(zap.String("foo", "bar")).Info(tenant)
+		}()
+	}
+
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 20)
+
+	for _, line := range lines {
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Equal(t, map[string]string{"tenant": strings.Fields(actual.Message)[0]}, actual.Labels)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithCorrelationFromContext(correlationKey{}, "correlationId")))
+
+	ctx := NewContext(context.Background(), LogUser("foo"))
+	ctx = WithContext(ctx, logger)
+	ctx = context.WithValue(ctx, correlationKey{}, "abc123")
+	child := NewContext(ctx, LogLabels(map[string]string{"bar": "baz"}))
+	sibling := NewContext(ctx, LogLabels(map[string]string{"qux": "quux"}))
+
+	log := func(ctx context.Context) logEntry {
+		defer writer.Reset()
+
+		FromContext(ctx).Info("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual
+	}
+
+	entry := log(child)
+	assert.Equal(t, "foo", entry.Context.User)
+	assert.Equal(t, map[string]string{"bar": "baz", "correlationId": "abc123"}, entry.Labels)
+
+	entry = log(sibling)
+	assert.Equal(t, "foo", entry.Context.User)
+	assert.Equal(t, map[string]string{"qux": "quux", "correlationId": "abc123"}, entry.Labels)
+
+	assert.NotNil(t, FromContext(context.Background()))
+}
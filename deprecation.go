@@ -0,0 +1,52 @@
+package stackdriver
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type deprecation struct {
+	feature     string
+	replacement string
+}
+
+func (d *deprecation) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("feature", d.feature)
+
+	if d.replacement != "" {
+		e.AddString("replacement", d.replacement)
+	}
+
+	return nil
+}
+
+// DeprecationLogger logs deprecation warnings once per feature.
+type DeprecationLogger struct {
+	logger *zap.Logger
+
+	// logged holds the features already logged.
+	logged sync.Map
+}
+
+// NewDeprecationLogger returns a DeprecationLogger writing to logger, whose
+// set of logged features starts empty.
+func NewDeprecationLogger(logger *zap.Logger) *DeprecationLogger {
+	return &DeprecationLogger{logger: logger.WithOptions(zap.AddCallerSkip(1))}
+}
+
+// LogDeprecationOnce logs as a WARNING that feature is deprecated in favor of
+// replacement, which may be empty. It only logs the first call for a feature,
+// so that it can be called every time the feature is used. The caller of
+// LogDeprecationOnce is the caller of the entry.
+func (d *DeprecationLogger) LogDeprecationOnce(feature, replacement string) {
+	if _, logged := d.logged.LoadOrStore(feature, struct{}{}); logged {
+		return
+	}
+
+	d.logger.Warn("deprecated: "+feature, zap.Object("deprecation", &deprecation{
+		feature:     feature,
+		replacement: replacement,
+	}))
+}
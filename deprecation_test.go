@@ -0,0 +1,47 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLogDeprecationOnce(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	deprecations := NewDeprecationLogger(zap.New(newCore(writer), zap.AddCaller()))
+
+	deprecations.LogDeprecationOnce("v1 API", "v2 API")
+	deprecations.LogDeprecationOnce("v1 API", "v2 API")
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var actual struct {
+		logEntry
+
+		Caller      string                 `json:"caller"`
+		Deprecation map[string]interface{} `json:"deprecation"`
+	}
+
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &actual))
+	assert.Equal(t, "WARNING", actual.Severity)
+	assert.Equal(t, "deprecated: v1 API", actual.Message)
+	assert.Contains(t, actual.Caller, "deprecation_test.go")
+	assert.Equal(t, map[string]interface{}{
+		"feature":     "v1 API",
+		"replacement": "v2 API",
+	}, actual.Deprecation)
+
+	writer.Reset()
+	deprecations.LogDeprecationOnce("legacy config", "")
+	assert.Contains(t, writer.String(), "legacy config")
+
+	writer.Reset()
+	NewDeprecationLogger(zap.New(newCore(writer))).LogDeprecationOnce("v1 API", "v2 API")
+	assert.Contains(t, writer.String(), "v1 API")
+}
@@ -0,0 +1,71 @@
+package stackdriver
+
+import (
+	"io"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type ColorMode int
+
+const (
+	// ColorAuto colors severities only when the output is a terminal.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+const colorReset = "\x1b[0m"
+
+var logLevelColor = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "\x1b[35m",
+	zapcore.InfoLevel:   "\x1b[34m",
+	noticeLevel:         "\x1b[36m",
+	zapcore.WarnLevel:   "\x1b[33m",
+	zapcore.ErrorLevel:  "\x1b[31m",
+	zapcore.DPanicLevel: "\x1b[31m",
+	zapcore.PanicLevel:  "\x1b[31m",
+	zapcore.FatalLevel:  "\x1b[31m",
+}
+
+// DevelopmentEncoderConfig returns an encoder config meant for the console
+// encoder when developing locally, which writes the same severities as
+// EncoderConfig. Whether they are colored depends on mode and w.
+func DevelopmentEncoderConfig(w io.Writer, mode ColorMode) zapcore.EncoderConfig {
+	config := EncoderConfig
+
+	if mode == ColorAlways || (mode == ColorAuto && IsTerminal(w)) {
+		config.EncodeLevel = EncodeColorLevel
+	}
+
+	return config
+}
+
+// IsTerminal reports whether w is a file connected to a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func EncodeColorLevel(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	color, ok := logLevelColor[lv]
+
+	if !ok {
+		EncodeLevel(lv, enc)
+		return
+	}
+
+	enc.AppendString(color + logLevelSeverity[lv] + colorReset)
+}
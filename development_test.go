@@ -0,0 +1,68 @@
+package stackdriver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDevelopmentEncoderConfig(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Mode     ColorMode
+		Expected string
+	}{
+		{
+			Name:     "Always",
+			Mode:     ColorAlways,
+			Expected: "\x1b[33mWARNING\x1b[0m\ttest\n",
+		},
+		{
+			Name:     "Never",
+			Mode:     ColorNever,
+			Expected: "WARNING\ttest\n",
+		},
+		{
+			Name:     "Auto without terminal",
+			Mode:     ColorAuto,
+			Expected: "WARNING\ttest\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			writer := bytes.NewBuffer(nil)
+			config := DevelopmentEncoderConfig(writer, test.Mode)
+			config.TimeKey = ""
+
+			enc := zapcore.NewConsoleEncoder(config)
+			logger := zap.New(zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.DebugLevel))
+			logger.Warn("test")
+
+			assert.Equal(t, test.Expected, writer.String())
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	assert.False(t, IsTerminal(bytes.NewBuffer(nil)))
+
+	f, err := ioutil.TempFile("", "")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	assert.False(t, IsTerminal(f))
+}
+
+func TestEncodeColorLevel(t *testing.T) {
+	enc := new(PrimitiveArrayEncoder)
+	enc.On("AppendString", "\x1b[31mERROR\x1b[0m").Once()
+	EncodeColorLevel(zapcore.ErrorLevel, enc)
+	enc.AssertExpectations(t)
+}
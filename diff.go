@@ -0,0 +1,51 @@
+package stackdriver
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldDiffer keeps the fields it was last given, to log only the ones which
+// changed since. It's safe for concurrent use.
+type FieldDiffer struct {
+	mu   sync.Mutex
+	last map[string]zapcore.Field
+}
+
+func NewFieldDiffer() *FieldDiffer {
+	return &FieldDiffer{
+		last: map[string]zapcore.Field{},
+	}
+}
+
+// Diff returns the fields which are new or differ from the field with the
+// same key given in a previous call. Fields are compared with
+// zapcore.Field.Equals, so objects which are mutated in place after being
+// logged are compared against their current value.
+func (d *FieldDiffer) Diff(fields ...zapcore.Field) []zapcore.Field {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var output []zapcore.Field
+
+	for _, f := range fields {
+		if last, ok := d.last[f.Key]; ok && last.Equals(f) {
+			continue
+		}
+
+		d.last[f.Key] = f
+		output = append(output, f)
+	}
+
+	return output
+}
+
+// Reset forgets the fields given so far, so that the next call to Diff
+// returns all of its fields.
+func (d *FieldDiffer) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.last = map[string]zapcore.Field{}
+}
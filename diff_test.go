@@ -0,0 +1,48 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFieldDiffer(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	differ := NewFieldDiffer()
+
+	log := func(state string, workers int, paused bool) map[string]interface{} {
+		defer writer.Reset()
+
+		logger.Info("state", differ.Diff(
+			zap.String("state", state),
+			zap.Int("workers", workers),
+			zap.Bool("paused", paused),
+		)...)
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		delete(actual, "severity")
+		delete(actual, "timestamp")
+		delete(actual, "message")
+		delete(actual, "context")
+		return actual
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"state":   "running",
+		"workers": float64(4),
+		"paused":  false,
+	}, log("running", 4, false))
+	assert.Equal(t, map[string]interface{}{
+		"workers": float64(8),
+	}, log("running", 8, false))
+	assert.Empty(t, log("running", 8, false))
+
+	differ.Reset()
+	assert.Len(t, log("running", 8, false), 3)
+}
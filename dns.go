@@ -0,0 +1,65 @@
+package stackdriver
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type dnsLookup struct {
+	host     string
+	duration time.Duration
+	results  int
+	err      error
+}
+
+func (l *dnsLookup) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("host", l.host)
+	e.AddDuration("duration", l.duration)
+	e.AddInt("results", l.results)
+
+	if l.err != nil {
+		e.AddString("error", l.err.Error())
+	}
+
+	return nil
+}
+
+// LogDNSLookup logs how long resolving host took and how many addresses it
+// resolved to.
+func LogDNSLookup(host string, d time.Duration, results int, err error) zapcore.Field {
+	return zap.Object("dns", &dnsLookup{
+		host:     host,
+		duration: d,
+		results:  results,
+		err:      err,
+	})
+}
+
+// HostResolver resolves host names, like *net.Resolver does.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// LookupHost resolves host with r and logs the lookup at DEBUG with
+// LogDNSLookup, or at WARN if it failed, with its caller as the caller of the
+// entry.
+func LookupHost(ctx context.Context, logger *zap.Logger, r HostResolver, host string) ([]string, error) {
+	start := time.Now()
+	addrs, err := r.LookupHost(ctx, host)
+	d := time.Since(start)
+
+	level := zapcore.DebugLevel
+
+	if err != nil {
+		level = zapcore.WarnLevel
+	}
+
+	if ce := logger.WithOptions(zap.AddCallerSkip(1)).Check(level, "dns lookup"); ce != nil {
+		ce.Write(LogDNSLookup(host, d, len(addrs), err))
+	}
+
+	return addrs, err
+}
@@ -0,0 +1,78 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stubResolver struct {
+	addrs []string
+	err   error
+	delay time.Duration
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	time.Sleep(r.delay)
+	return r.addrs, r.err
+}
+
+func TestLookupHost(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	type dnsEntry struct {
+		logEntry
+
+		DNS map[string]interface{} `json:"dns"`
+	}
+
+	t.Run("Resolved", func(t *testing.T) {
+		defer writer.Reset()
+
+		r := &stubResolver{addrs: []string{"10.0.0.1", "10.0.0.2"}, delay: 5 * time.Millisecond}
+		addrs, err := LookupHost(context.Background(), logger, r, "db.internal")
+		require.Nil(t, err)
+		assert.Equal(t, r.addrs, addrs)
+
+		var actual dnsEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "DEBUG", actual.Severity)
+		assert.Equal(t, "db.internal", actual.DNS["host"])
+		assert.Equal(t, float64(2), actual.DNS["results"])
+		assert.GreaterOrEqual(t, actual.DNS["duration"], float64(5))
+		assert.NotContains(t, actual.DNS, "error")
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		defer writer.Reset()
+
+		_, err := LookupHost(context.Background(), logger, &stubResolver{err: errors.New("no such host")}, "db.internal")
+		require.NotNil(t, err)
+
+		var actual dnsEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "WARNING", actual.Severity)
+		assert.Equal(t, float64(0), actual.DNS["results"])
+		assert.Equal(t, "no such host", actual.DNS["error"])
+	})
+}
+
+func TestLookupHost_Caller(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	LookupHost(context.Background(), zap.New(newCore(writer), zap.AddCaller()), &stubResolver{}, "db.internal")
+
+	var actual struct {
+		Caller string `json:"caller"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Contains(t, actual.Caller, "dns_test.go")
+}
@@ -0,0 +1,57 @@
+package stackdriver
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithElevatedSeverity makes a logger write the entries logged below min with
+// the severity of min, such as during an incident:
+//
+//	incident := logger.WithOptions(stackdriver.WithElevatedSeverity(zapcore.WarnLevel))
+//
+// Whether an entry is written still depends on the level it's logged at.
+func WithElevatedSeverity(min zapcore.Level) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &elevatedCore{
+			Core: core,
+			min:  min,
+		}
+	})
+}
+
+type elevatedCore struct {
+	zapcore.Core
+
+	min zapcore.Level
+}
+
+func (c *elevatedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &elevatedCore{
+		Core: c.Core.With(fields),
+		min:  c.min,
+	}
+}
+
+// Check checks the elevated entry with the wrapped core, so that its own
+// decisions, such as sampling, still apply, once the level the entry is logged
+// at is enabled.
+func (c *elevatedCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return ce
+	}
+
+	if entry.Level < c.min {
+		entry.Level = c.min
+	}
+
+	return c.Core.Check(entry, ce)
+}
+
+func (c *elevatedCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Level < c.min {
+		entry.Level = c.min
+	}
+
+	return c.Core.Write(entry, fields)
+}
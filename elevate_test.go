@@ -0,0 +1,57 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWithElevatedSeverity(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	logger.Info("before")
+
+	func() {
+		incident := logger.WithOptions(WithElevatedSeverity(zapcore.WarnLevel)).With(zap.String("foo", "bar"))
+		incident.Info("during")
+		incident.Error("during")
+	}()
+
+	logger.Info("after")
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 4)
+
+	var severities []string
+
+	for _, line := range lines {
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		severities = append(severities, actual.Severity)
+	}
+
+	assert.Equal(t, []string{"INFO", "WARNING", "ERROR", "INFO"}, severities)
+}
+
+func TestWithElevatedSeverity_Sampling(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithSampling(1, 0))).WithOptions(WithElevatedSeverity(zapcore.WarnLevel))
+
+	for i := 0; i < 5; i++ {
+		logger.Info("foo")
+	}
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &actual))
+	assert.Equal(t, "WARNING", actual.Severity)
+}
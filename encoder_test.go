@@ -0,0 +1,111 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newEncoderLogger(writer *bytes.Buffer) *zap.Logger {
+	return zap.New(zapcore.NewCore(NewEncoder(EncoderConfig), zapcore.AddSync(writer), zapcore.DebugLevel))
+}
+
+func TestNewEncoder(t *testing.T) {
+	coreWriter := bytes.NewBuffer(nil)
+	encoderWriter := bytes.NewBuffer(nil)
+
+	log := func(writer *bytes.Buffer, logger *zap.Logger) map[string]interface{} {
+		defer writer.Reset()
+
+		child := logger.With(
+			LogUser("alice"),
+			LogLabels(map[string]string{"foo": "bar"}),
+			LogTrace("foo", testTraceID),
+			LogSpanID("00f067aa0ba902b7"),
+			zap.String("baz", "qux"),
+		)
+		child.Warn("foo",
+			LogHTTPRequest(&HTTPRequest{Method: "GET", URL: "/foo", Latency: time.Second}),
+			LogOperation("op", "bar", true, false),
+			LogLabels(map[string]string{"job": "baz"}),
+			RemoveLabel("foo"),
+			LogTraceSampled(true),
+			zap.Int("n", 1),
+		)
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		delete(actual, "timestamp")
+		return actual
+	}
+
+	expected := log(coreWriter, zap.New(newCore(coreWriter)))
+	assert.Equal(t, expected, log(encoderWriter, newEncoderLogger(encoderWriter)))
+	assert.Equal(t, map[string]interface{}{"job": "baz"}, expected[logKeyLabels])
+}
+
+func TestNewEncoder_Labels(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := newEncoderLogger(writer).With(LogLabels(map[string]string{"foo": "bar"}))
+
+	labels := func(logger *zap.Logger, fields ...zapcore.Field) map[string]string {
+		defer writer.Reset()
+
+		logger.Info("", fields...)
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Labels
+	}
+
+	child := logger.With(LogLabels(map[string]string{"baz": "qux"}))
+	assert.Equal(t, map[string]string{"foo": "bar", "baz": "qux"}, labels(child))
+	assert.Equal(t, map[string]string{"foo": "bar", "job": "1"}, labels(logger, LogLabels(map[string]string{"job": "1"})))
+	assert.Equal(t, map[string]string{"foo": "bar"}, labels(logger))
+	assert.Equal(t, map[string]string{"foo": "bar"}, labels(logger.With(RemoveLabel("baz"))))
+}
+
+func TestNewEncoder_Errors(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := newEncoderLogger(writer).WithOptions(zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+
+	logger.Error("foo")
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, typeReportedErrorEvent, actual[logKeyType])
+
+	location := actual[logKeySourceLocation].(map[string]interface{})
+	assert.Equal(t, "github.com/pablote/zap-stackdriver.TestNewEncoder_Errors", location["function"])
+}
+
+func benchmarkLogger(b *testing.B, logger *zap.Logger) {
+	child := logger.With(
+		LogUser("alice"),
+		LogLabels(map[string]string{"team": "foo"}),
+		LogTrace("foo", testTraceID),
+	)
+	req := &HTTPRequest{Method: "GET", URL: "/foo", ResponseStatusCode: 200}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		child.Info("request served", LogHTTPRequest(req), zap.String("foo", "bar"), zap.Int("n", i))
+	}
+}
+
+func BenchmarkCore(b *testing.B) {
+	benchmarkLogger(b, zap.New(NewJSONCore(zapcore.AddSync(ioutil.Discard), zapcore.InfoLevel)))
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	benchmarkLogger(b, zap.New(zapcore.NewCore(NewEncoder(EncoderConfig), zapcore.AddSync(ioutil.Discard), zapcore.InfoLevel)))
+}
@@ -0,0 +1,57 @@
+package stackdriver
+
+import (
+	"os"
+	"strings"
+)
+
+const labelEnvironment = "env"
+
+// EnvironmentVariables are read in order by DetectEnvironment.
+var EnvironmentVariables = []string{"ENVIRONMENT", "ENV", "APP_ENV"}
+
+var environmentAliases = map[string]string{
+	"production":  "prod",
+	"stage":       "staging",
+	"development": "dev",
+	"local":       "dev",
+}
+
+// DetectEnvironment returns the environment named by the first variable of
+// EnvironmentVariables that's set, such as "prod", "staging" or "dev". Other
+// common spellings like "production" are mapped to these. It returns an empty
+// string if none is set.
+func DetectEnvironment() string {
+	for _, name := range EnvironmentVariables {
+		env := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+
+		if env == "" {
+			continue
+		}
+
+		if alias, ok := environmentAliases[env]; ok {
+			return alias
+		}
+
+		return env
+	}
+
+	return ""
+}
+
+// WithEnvironmentDetector adds the environment returned by detect, such as
+// DetectEnvironment, to every entry as the "env" label. detect is called
+// once, and no label is added if it returns an empty string.
+func WithEnvironmentDetector(detect func() string) Option {
+	return func(c *Core) {
+		env := detect()
+
+		if env == "" {
+			return
+		}
+
+		ctx := c.cloneCtx()
+		ctx.setLabel(labelEnvironment, env)
+		c.ctx = ctx
+	}
+}
@@ -0,0 +1,55 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDetectEnvironment(t *testing.T) {
+	for _, name := range EnvironmentVariables {
+		value, ok := os.LookupEnv(name)
+		os.Unsetenv(name)
+
+		if ok {
+			defer os.Setenv(name, value)
+		}
+	}
+
+	assert.Equal(t, "", DetectEnvironment())
+
+	os.Setenv("APP_ENV", "Production")
+	defer os.Unsetenv("APP_ENV")
+	assert.Equal(t, "prod", DetectEnvironment())
+
+	os.Setenv("ENV", "qa")
+	defer os.Unsetenv("ENV")
+	assert.Equal(t, "qa", DetectEnvironment())
+}
+
+func TestWithEnvironmentDetector(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithEnvironmentDetector(func() string {
+		return "staging"
+	})))
+
+	logger.With(zap.String("foo", "bar")).Info("")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"env": "staging"}, actual.Labels)
+
+	writer.Reset()
+	zap.New(newTestCore(writer, WithEnvironmentDetector(func() string {
+		return ""
+	}))).Info("")
+
+	actual = logEntry{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Empty(t, actual.Labels)
+}
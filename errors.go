@@ -0,0 +1,107 @@
+package stackdriver
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type contextError struct {
+	error
+}
+
+func (e contextError) level(lv zapcore.Level) zapcore.Level {
+	var max zapcore.Level
+
+	switch {
+	case errors.Is(e.error, context.Canceled):
+		max = zapcore.InfoLevel
+	case errors.Is(e.error, context.DeadlineExceeded):
+		max = zapcore.WarnLevel
+	default:
+		return lv
+	}
+
+	if lv > max {
+		return max
+	}
+
+	return lv
+}
+
+func (e contextError) labels() map[string]string {
+	switch {
+	case errors.Is(e.error, context.Canceled):
+		return map[string]string{"canceled": "true"}
+	case errors.Is(e.error, context.DeadlineExceeded):
+		return map[string]string{"timeout": "true"}
+	}
+
+	return nil
+}
+
+// LogContextError logs err like zap.Error does. When err is (or wraps)
+// context.Canceled or context.DeadlineExceeded the entry is downgraded to INFO
+// or WARNING respectively and labelled with canceled or timeout, since these
+// are usually not real errors.
+func LogContextError(err error) zapcore.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+
+	return zapcore.Field{
+		Key:       "error",
+		Type:      zapcore.ErrorType,
+		Interface: contextError{err},
+	}
+}
+
+type httpError struct {
+	err    error
+	status int
+	loc    *ReportLocation
+}
+
+func (e *httpError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("status", e.status)
+	enc.AddString("error", e.err.Error())
+	return nil
+}
+
+func (e *httpError) level(lv zapcore.Level) zapcore.Level {
+	switch {
+	case e.status >= 500:
+		return zapcore.ErrorLevel
+	case e.status >= 400:
+		return zapcore.WarnLevel
+	}
+
+	return lv
+}
+
+func (e *httpError) reportLocation() *ReportLocation {
+	return e.loc
+}
+
+// LogHTTPError logs err with the HTTP status it was answered with, as an
+// httpError object. A 5xx status makes the entry an ERROR reported at the
+// caller's location and a 4xx status makes it a WARNING, whatever level it
+// was logged at.
+func LogHTTPError(err error, status int) zapcore.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+
+	e := &httpError{
+		err:    err,
+		status: status,
+	}
+
+	if status >= 500 {
+		e.loc = callerLocation(1)
+	}
+
+	return zap.Object("httpError", e)
+}
@@ -0,0 +1,129 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogContextError(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Error("", LogContextError(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+
+		var actual struct {
+			logEntry
+
+			Error string `json:"error"`
+		}
+
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "WARNING", actual.Severity)
+		assert.Equal(t, map[string]string{"timeout": "true"}, actual.Labels)
+		assert.Equal(t, "query: context deadline exceeded", actual.Error)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Error("", LogContextError(context.Canceled))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "INFO", actual.Severity)
+		assert.Equal(t, map[string]string{"canceled": "true"}, actual.Labels)
+	})
+
+	t.Run("Never raises severity", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Debug("", LogContextError(context.DeadlineExceeded))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "DEBUG", actual.Severity)
+	})
+
+	t.Run("Other error", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Error("", LogContextError(errors.New("random error")))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "ERROR", actual.Severity)
+		assert.Nil(t, actual.Labels)
+	})
+
+	t.Run("Dropped when downgraded below level", func(t *testing.T) {
+		defer writer.Reset()
+
+		enc := zapcore.NewJSONEncoder(EncoderConfig)
+		logger := zap.New(&Core{
+			Core: zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.WarnLevel),
+		})
+		logger.Error("", LogContextError(context.Canceled))
+
+		assert.Empty(t, writer.String())
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		assert.Equal(t, zap.Skip(), LogContextError(nil))
+	})
+}
+
+func TestLogHTTPError(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	type httpErrorEntry struct {
+		logEntry
+
+		HTTPError map[string]interface{} `json:"httpError"`
+	}
+
+	t.Run("4xx", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Info("", LogHTTPError(errors.New("not found"), 404))
+
+		var actual httpErrorEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "WARNING", actual.Severity)
+		assert.Equal(t, map[string]interface{}{"status": float64(404), "error": "not found"}, actual.HTTPError)
+		assert.Nil(t, actual.Context.ReportLocation)
+	})
+
+	t.Run("5xx", func(t *testing.T) {
+		defer writer.Reset()
+
+		_, file, line, _ := runtime.Caller(0)
+		logger.Info("", LogHTTPError(errors.New("unavailable"), 503))
+
+		var actual httpErrorEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "ERROR", actual.Severity)
+		assert.Equal(t, map[string]interface{}{"status": float64(503), "error": "unavailable"}, actual.HTTPError)
+		require.NotNil(t, actual.Context.ReportLocation)
+		assert.Equal(t, file, actual.Context.ReportLocation.FilePath)
+		assert.Equal(t, line+1, actual.Context.ReportLocation.LineNumber)
+		assert.Contains(t, actual.Context.ReportLocation.FunctionName, "TestLogHTTPError")
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		assert.Equal(t, zap.Skip(), LogHTTPError(nil, 500))
+	})
+}
@@ -1,6 +1,8 @@
 package stackdriver_test
 
 import (
+	"os"
+
 	"github.com/pablote/zap-stackdriver"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,11 +17,7 @@ func Example_basic() {
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
-	logger, err := config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
-		return &stackdriver.Core{
-			Core: core,
-		}
-	}), zap.Fields(
+	logger, err := config.Build(stackdriver.WrapCore(), zap.Fields(
 		stackdriver.LogServiceContext(&stackdriver.ServiceContext{
 			Service: "foo",
 			Version: "bar",
@@ -41,3 +39,13 @@ func Example_basic() {
 			RemoteIP:           "1.2.3.4",
 		}))
 }
+
+func Example_newJSONCore() {
+	logger := zap.New(stackdriver.NewJSONCore(os.Stdout, zapcore.InfoLevel,
+		stackdriver.WithReportLocation(true),
+		stackdriver.WithServiceContext("foo", "bar"),
+		stackdriver.WithLabels(map[string]string{"team": "baz"}),
+	), zap.AddCaller())
+
+	logger.Info("Hello")
+}
@@ -0,0 +1,63 @@
+package stackdriver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type experimentExposure struct {
+	experiment string
+	variant    string
+	userID     string
+}
+
+func (x *experimentExposure) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", x.experiment)
+	e.AddString("variant", x.variant)
+
+	if x.userID != "" {
+		e.AddString("userId", x.userID)
+	}
+
+	return nil
+}
+
+type ExposureOption func(*experimentExposure)
+
+// WithHashedUserID replaces the user ID with the hex SHA-256 hash of salt
+// followed by the ID, so that exposures can still be counted per user
+// without logging who the users are.
+func WithHashedUserID(salt string) ExposureOption {
+	return func(x *experimentExposure) {
+		if x.userID == "" {
+			return
+		}
+
+		x.userID = hashString(salt + x.userID)
+	}
+}
+
+// LogExperimentExposure logs that the user with userID was exposed to variant
+// of an experiment, for A/B analysis based on logs.
+func LogExperimentExposure(experiment, variant, userID string, opts ...ExposureOption) zapcore.Field {
+	x := &experimentExposure{
+		experiment: experiment,
+		variant:    variant,
+		userID:     userID,
+	}
+
+	for _, opt := range opts {
+		opt(x)
+	}
+
+	return zap.Object("experiment", x)
+}
+
+// hashString returns the hex SHA-256 hash of s.
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
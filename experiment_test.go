@@ -0,0 +1,53 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogExperimentExposure(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	log := func(field zapcore.Field) interface{} {
+		defer writer.Reset()
+
+		logger.Info("", field)
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual["experiment"]
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{
+			"name":    "checkout-button",
+			"variant": "green",
+			"userId":  "user:42",
+		}, log(LogExperimentExposure("checkout-button", "green", "user:42")))
+	})
+
+	t.Run("Hashed user ID", func(t *testing.T) {
+		actual := log(LogExperimentExposure("checkout-button", "green", "user:42", WithHashedUserID("foo")))
+
+		require.IsType(t, map[string]interface{}{}, actual)
+		userID := actual.(map[string]interface{})["userId"]
+		assert.Len(t, userID, 64)
+		assert.NotContains(t, userID, "user:42")
+		assert.Equal(t, actual, log(LogExperimentExposure("checkout-button", "green", "user:42", WithHashedUserID("foo"))))
+		assert.NotEqual(t, actual, log(LogExperimentExposure("checkout-button", "green", "user:42", WithHashedUserID("bar"))))
+	})
+
+	t.Run("No user ID", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{
+			"name":    "checkout-button",
+			"variant": "control",
+		}, log(LogExperimentExposure("checkout-button", "control", "", WithHashedUserID("foo"))))
+	})
+}
@@ -0,0 +1,915 @@
+package stackdriver
+
+import (
+	"context"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type deadline struct {
+	set       bool
+	remaining time.Duration
+}
+
+func (d *deadline) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddBool("set", d.set)
+
+	if d.set {
+		e.AddDuration("remaining", d.remaining)
+	}
+
+	return nil
+}
+
+// LogDeadline logs whether ctx has a deadline and how much time is left
+// before it expires. The remaining time is negative once it has passed.
+func LogDeadline(ctx context.Context) zapcore.Field {
+	d := &deadline{}
+
+	if t, ok := ctx.Deadline(); ok {
+		d.set = true
+		d.remaining = time.Until(t)
+	}
+
+	return zap.Object("deadline", d)
+}
+
+type pagination struct {
+	page     int
+	pageSize int
+	total    int64
+	cursor   string
+}
+
+func (p *pagination) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddInt("page", p.page)
+	e.AddInt("pageSize", p.pageSize)
+	e.AddInt64("total", p.total)
+
+	if p.cursor != "" {
+		e.AddString("cursor", p.cursor)
+	}
+
+	return nil
+}
+
+func LogPagination(page, pageSize int, total int64, cursor string) zapcore.Field {
+	return zap.Object("pagination", &pagination{
+		page:     page,
+		pageSize: pageSize,
+		total:    total,
+		cursor:   cursor,
+	})
+}
+
+type rateLimit struct {
+	key       string
+	allowed   bool
+	remaining int
+	resetAt   time.Time
+}
+
+func (r *rateLimit) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("key", r.key)
+	e.AddBool("allowed", r.allowed)
+	e.AddInt("remaining", r.remaining)
+	e.AddTime("resetAt", r.resetAt)
+	return nil
+}
+
+func LogRateLimit(key string, allowed bool, remaining int, resetAt time.Time) zapcore.Field {
+	return zap.Object("rateLimit", &rateLimit{
+		key:       key,
+		allowed:   allowed,
+		remaining: remaining,
+		resetAt:   resetAt,
+	})
+}
+
+type retryAfter struct {
+	endpoint string
+	delay    time.Duration
+}
+
+func (r *retryAfter) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("endpoint", r.endpoint)
+	e.AddDuration("delay", r.delay)
+	return nil
+}
+
+// LogRetryAfter logs that a client waits for delay before retrying a request
+// to endpoint, as asked by its Retry-After header.
+func LogRetryAfter(endpoint string, delay time.Duration) zapcore.Field {
+	return zap.Object("retryAfter", &retryAfter{
+		endpoint: endpoint,
+		delay:    delay,
+	})
+}
+
+type cacheOp struct {
+	op       string
+	key      string
+	hit      bool
+	duration time.Duration
+}
+
+func (c *cacheOp) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("op", c.op)
+	e.AddString("key", c.key)
+	e.AddBool("hit", c.hit)
+	e.AddDuration("duration", c.duration)
+	return nil
+}
+
+func LogCacheOp(op string, key string, hit bool, d time.Duration) zapcore.Field {
+	return zap.Object("cache", &cacheOp{
+		op:       op,
+		key:      key,
+		hit:      hit,
+		duration: d,
+	})
+}
+
+type lock struct {
+	name     string
+	acquired bool
+	waited   time.Duration
+	holder   string
+}
+
+func (l *lock) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", l.name)
+	e.AddBool("acquired", l.acquired)
+	e.AddDuration("waited", l.waited)
+
+	if l.holder != "" {
+		e.AddString("holder", l.holder)
+	}
+
+	return nil
+}
+
+// LogLock logs an attempt to acquire a distributed lock, how long it waited
+// and who holds the lock, if known.
+func LogLock(name string, acquired bool, waited time.Duration, holder string) zapcore.Field {
+	return zap.Object("lock", &lock{
+		name:     name,
+		acquired: acquired,
+		waited:   waited,
+		holder:   holder,
+	})
+}
+
+type queueStats struct {
+	name      string
+	depth     int
+	inFlight  int
+	oldestAge time.Duration
+}
+
+func (q *queueStats) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", q.name)
+	e.AddInt("depth", q.depth)
+	e.AddInt("inFlight", q.inFlight)
+	e.AddDuration("oldestAge", q.oldestAge)
+	return nil
+}
+
+// LogQueueStats logs how many messages of a queue are waiting and being
+// processed, and how long the oldest one has been waiting.
+func LogQueueStats(name string, depth int, inFlight int, oldestAge time.Duration) zapcore.Field {
+	return zap.Object("queue", &queueStats{
+		name:      name,
+		depth:     depth,
+		inFlight:  inFlight,
+		oldestAge: oldestAge,
+	})
+}
+
+type healthCheck struct {
+	name     string
+	healthy  bool
+	duration time.Duration
+	detail   string
+}
+
+func (h *healthCheck) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", h.name)
+	e.AddBool("healthy", h.healthy)
+	e.AddDuration("duration", h.duration)
+
+	if h.detail != "" {
+		e.AddString("detail", h.detail)
+	}
+
+	return nil
+}
+
+// LogHealthCheck logs the result of checking the health of a dependency, with
+// the same shape for every dependency so that their results can be
+// aggregated.
+func LogHealthCheck(name string, healthy bool, d time.Duration, detail string) zapcore.Field {
+	return zap.Object("healthCheck", &healthCheck{
+		name:     name,
+		healthy:  healthy,
+		duration: d,
+		detail:   detail,
+	})
+}
+
+type migration struct {
+	version   string
+	direction string
+	duration  time.Duration
+	err       error
+}
+
+func (m *migration) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("version", m.version)
+	e.AddString("direction", m.direction)
+	e.AddDuration("duration", m.duration)
+
+	if m.err != nil {
+		e.AddString("error", m.err.Error())
+	}
+
+	return nil
+}
+
+func (m *migration) level(zapcore.Level) zapcore.Level {
+	if m.err != nil {
+		return zapcore.ErrorLevel
+	}
+
+	return noticeLevel
+}
+
+// LogMigration logs a schema migration step, such as applying version up or
+// down. The entry is a NOTICE, or an ERROR if err isn't nil.
+func LogMigration(version string, direction string, d time.Duration, err error) zapcore.Field {
+	return zap.Object("migration", &migration{
+		version:   version,
+		direction: direction,
+		duration:  d,
+		err:       err,
+	})
+}
+
+// jobStatusLevel maps the statuses of background jobs to the level of the
+// entries logging them.
+var jobStatusLevel = map[string]zapcore.Level{
+	"started":   zapcore.InfoLevel,
+	"succeeded": zapcore.InfoLevel,
+	"skipped":   zapcore.InfoLevel,
+	"retrying":  zapcore.WarnLevel,
+	"canceled":  zapcore.WarnLevel,
+	"failed":    zapcore.ErrorLevel,
+}
+
+type job struct {
+	name     string
+	status   string
+	duration time.Duration
+	attempt  int
+	err      error
+}
+
+func (j *job) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", j.name)
+	e.AddString("status", j.status)
+	e.AddDuration("duration", j.duration)
+	e.AddInt("attempt", j.attempt)
+
+	if j.err != nil {
+		e.AddString("error", j.err.Error())
+	}
+
+	return nil
+}
+
+func (j *job) level(lv zapcore.Level) zapcore.Level {
+	if level, ok := jobStatusLevel[j.status]; ok {
+		return level
+	}
+
+	return lv
+}
+
+// LogJob logs an attempt to run a background job. The entry is an INFO when
+// status is started, succeeded or skipped, a WARNING when it's retrying or
+// canceled and an ERROR when it's failed. Other statuses keep the level the
+// entry is logged at.
+func LogJob(name string, status string, d time.Duration, attempt int, err error) zapcore.Field {
+	return zap.Object("job", &job{
+		name:     name,
+		status:   status,
+		duration: d,
+		attempt:  attempt,
+		err:      err,
+	})
+}
+
+type circuitBreaker struct {
+	name   string
+	from   string
+	to     string
+	reason string
+}
+
+func (b *circuitBreaker) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", b.name)
+	e.AddString("from", b.from)
+	e.AddString("to", b.to)
+
+	if b.reason != "" {
+		e.AddString("reason", b.reason)
+	}
+
+	return nil
+}
+
+func (b *circuitBreaker) level(zapcore.Level) zapcore.Level {
+	if b.to == "open" {
+		return zapcore.WarnLevel
+	}
+
+	return zapcore.InfoLevel
+}
+
+// LogCircuitBreaker logs a circuit breaker going from one state to another,
+// such as "closed", "open" or "half-open". The entry is a WARNING when the
+// breaker opens and an INFO otherwise.
+func LogCircuitBreaker(name string, from, to string, reason string) zapcore.Field {
+	return zap.Object("circuitBreaker", &circuitBreaker{
+		name:   name,
+		from:   from,
+		to:     to,
+		reason: reason,
+	})
+}
+
+type webSocket struct {
+	event        string
+	id           string
+	duration     time.Duration
+	closeCode    int
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func (w *webSocket) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("event", w.event)
+	e.AddString("id", w.id)
+
+	if w.event == "disconnect" {
+		e.AddDuration("duration", w.duration)
+		e.AddInt("closeCode", w.closeCode)
+		e.AddInt64("bytesRead", w.bytesRead)
+		e.AddInt64("bytesWritten", w.bytesWritten)
+	}
+
+	return nil
+}
+
+// LogWebSocketConnect logs that the WebSocket connection id was opened.
+func LogWebSocketConnect(id string) zapcore.Field {
+	return zap.Object("webSocket", &webSocket{
+		event: "connect",
+		id:    id,
+	})
+}
+
+// LogWebSocketDisconnect logs that the WebSocket connection id was closed
+// with closeCode after being open for d, and how many bytes went through it.
+func LogWebSocketDisconnect(id string, d time.Duration, closeCode int, bytesRead, bytesWritten int64) zapcore.Field {
+	return zap.Object("webSocket", &webSocket{
+		event:        "disconnect",
+		id:           id,
+		duration:     d,
+		closeCode:    closeCode,
+		bytesRead:    bytesRead,
+		bytesWritten: bytesWritten,
+	})
+}
+
+type grpcStream struct {
+	method   string
+	received int
+	sent     int
+	duration time.Duration
+}
+
+func (g *grpcStream) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("method", g.method)
+	e.AddInt("messagesReceived", g.received)
+	e.AddInt("messagesSent", g.sent)
+	e.AddDuration("duration", g.duration)
+	return nil
+}
+
+// LogGRPCStream logs how many messages a streaming RPC to method received and
+// sent, and how long the stream lasted.
+func LogGRPCStream(method string, received, sent int, d time.Duration) zapcore.Field {
+	return zap.Object("grpcStream", &grpcStream{
+		method:   method,
+		received: received,
+		sent:     sent,
+		duration: d,
+	})
+}
+
+type grpcRetry struct {
+	decision string
+	method   string
+	attempt  int
+	policy   string
+}
+
+func (g *grpcRetry) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("decision", g.decision)
+	e.AddString("method", g.method)
+	e.AddInt("attempt", g.attempt)
+	e.AddString("policy", g.policy)
+	return nil
+}
+
+// LogGRPCRetry logs that a client retries the RPC method, attempt being the
+// number of the new attempt and policy the name of the retry policy.
+func LogGRPCRetry(method string, attempt int, policy string) zapcore.Field {
+	return zap.Object("grpcRetry", &grpcRetry{
+		decision: "retry",
+		method:   method,
+		attempt:  attempt,
+		policy:   policy,
+	})
+}
+
+// LogGRPCHedge logs that a client sends a hedged attempt of the RPC method,
+// in parallel with the attempts already in flight.
+func LogGRPCHedge(method string, attempt int, policy string) zapcore.Field {
+	return zap.Object("grpcRetry", &grpcRetry{
+		decision: "hedge",
+		method:   method,
+		attempt:  attempt,
+		policy:   policy,
+	})
+}
+
+type storageOp struct {
+	op       string
+	bucket   string
+	object   string
+	bytes    int64
+	duration time.Duration
+	err      error
+}
+
+func (s *storageOp) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("op", s.op)
+	e.AddString("bucket", s.bucket)
+	e.AddString("object", s.object)
+	e.AddInt64("bytes", s.bytes)
+	e.AddDuration("duration", s.duration)
+
+	if s.err != nil {
+		e.AddString("error", s.err.Error())
+	}
+
+	return nil
+}
+
+// LogStorageOp logs an operation on an object of a file or object storage
+// bucket, such as a read or a write of bytes bytes.
+func LogStorageOp(op, bucket, object string, bytes int64, d time.Duration, err error) zapcore.Field {
+	return zap.Object("storage", &storageOp{
+		op:       op,
+		bucket:   bucket,
+		object:   object,
+		bytes:    bytes,
+		duration: d,
+		err:      err,
+	})
+}
+
+// DefaultAuthClaims are the JWT claims logged by LogAuthClaims when no
+// allowlist is given.
+var DefaultAuthClaims = []string{"sub", "iss", "aud", "exp", "iat", "nbf"}
+
+type authClaims struct {
+	claims    map[string]interface{}
+	allowlist []string
+}
+
+func (a *authClaims) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	for _, k := range a.allowlist {
+		if v, ok := a.claims[k]; ok {
+			if err := e.AddReflected(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// LogAuthClaims logs the claims of a JWT whose names are in allowlist, or in
+// DefaultAuthClaims if it's empty, under the "claims" key. Other claims are
+// never logged.
+func LogAuthClaims(claims map[string]interface{}, allowlist []string) zapcore.Field {
+	if len(allowlist) == 0 {
+		allowlist = DefaultAuthClaims
+	}
+
+	return zap.Object("claims", &authClaims{
+		claims:    claims,
+		allowlist: allowlist,
+	})
+}
+
+type authFailure struct {
+	reason      string
+	attempts    int
+	lockedUntil time.Time
+}
+
+func (a *authFailure) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("reason", a.reason)
+	e.AddInt("attempts", a.attempts)
+
+	if !a.lockedUntil.IsZero() {
+		e.AddTime("lockedUntil", a.lockedUntil)
+	}
+
+	return nil
+}
+
+func (a *authFailure) level(zapcore.Level) zapcore.Level {
+	return zapcore.WarnLevel
+}
+
+// LogAuthFailure logs a failed authentication as a WARNING, after attempts
+// consecutive failures. lockedUntil is when the account can try again, or
+// the zero time if it isn't locked. The account itself isn't part of the
+// field so that usernames and emails don't end up in the logs.
+func LogAuthFailure(reason string, attempts int, lockedUntil time.Time) zapcore.Field {
+	return zap.Object("authFailure", &authFailure{
+		reason:      reason,
+		attempts:    attempts,
+		lockedUntil: lockedUntil,
+	})
+}
+
+type webhook struct {
+	url      string
+	status   int
+	duration time.Duration
+	attempt  int
+	err      error
+}
+
+func (w *webhook) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("url", w.url)
+
+	if w.status != 0 {
+		e.AddInt("status", w.status)
+	}
+
+	e.AddDuration("duration", w.duration)
+	e.AddInt("attempt", w.attempt)
+
+	if w.err != nil {
+		e.AddString("error", w.err.Error())
+	}
+
+	return nil
+}
+
+func (w *webhook) level(zapcore.Level) zapcore.Level {
+	switch {
+	case w.err != nil || w.status >= 500:
+		return zapcore.ErrorLevel
+	case w.status >= 400:
+		return zapcore.WarnLevel
+	}
+
+	return zapcore.InfoLevel
+}
+
+// LogWebhook logs the delivery of a webhook to rawURL, which answered with
+// status after d. The password and the query values of the URL are masked,
+// since they often hold tokens. The entry is an ERROR when err isn't nil or
+// status is a 5xx, a WARNING when it's a 4xx and an INFO otherwise.
+func LogWebhook(rawURL string, status int, d time.Duration, attempt int, err error) zapcore.Field {
+	return zap.Object("webhook", &webhook{
+		url:      maskURL(rawURL),
+		status:   status,
+		duration: d,
+		attempt:  attempt,
+		err:      err,
+	})
+}
+
+// maskedURLValue replaces secrets in URLs. Unlike redactedValue it doesn't
+// need to be escaped.
+const maskedURLValue = "REDACTED"
+
+// maskURL replaces the password and the query values of rawURL with
+// maskedURLValue, and drops its fragment. URLs that can't be parsed are
+// masked entirely.
+func maskURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return maskedURLValue
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), maskedURLValue)
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	params := make([]string, 0, len(query))
+
+	for k := range query {
+		params = append(params, url.QueryEscape(k)+"="+maskedURLValue)
+	}
+
+	sort.Strings(params)
+	u.RawQuery = strings.Join(params, "&")
+
+	return u.String()
+}
+
+// LogGoroutineStack logs the stack of the calling goroutine, whatever level
+// the entry is logged at. Unlike a stacktrace it doesn't make Error Reporting
+// pick up the entry.
+func LogGoroutineStack() zapcore.Field {
+	return zap.ByteString("goroutineStack", debug.Stack())
+}
+
+type tlsHandshakeError struct {
+	peer     string
+	protocol string
+	err      error
+}
+
+func (t *tlsHandshakeError) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("peer", t.peer)
+
+	if t.protocol != "" {
+		e.AddString("protocol", t.protocol)
+	}
+
+	e.AddString("error", t.err.Error())
+	return nil
+}
+
+func (t *tlsHandshakeError) level(zapcore.Level) zapcore.Level {
+	return zapcore.WarnLevel
+}
+
+// LogTLSHandshakeError logs a failed TLS handshake with peer as a WARNING.
+// protocol is the application protocol that was attempted, such as "h2", or
+// empty if none was. The field is skipped if err is nil.
+func LogTLSHandshakeError(peer string, protocol string, err error) zapcore.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+
+	return zap.Object("tlsHandshake", &tlsHandshakeError{
+		peer:     peer,
+		protocol: protocol,
+		err:      err,
+	})
+}
+
+type taskSkip struct {
+	name   string
+	reason string
+}
+
+func (t *taskSkip) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("name", t.name)
+	e.AddString("reason", t.reason)
+	return nil
+}
+
+func (t *taskSkip) level(zapcore.Level) zapcore.Level {
+	return noticeLevel
+}
+
+// LogTaskSkip logs as a NOTICE that a scheduler didn't run the task name,
+// such as because its previous run hasn't finished yet.
+func LogTaskSkip(name, reason string) zapcore.Field {
+	return zap.Object("taskSkip", &taskSkip{
+		name:   name,
+		reason: reason,
+	})
+}
+
+type leaderElection struct {
+	role     string
+	acquired bool
+	term     int
+}
+
+func (l *leaderElection) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("role", l.role)
+	e.AddBool("acquired", l.acquired)
+	e.AddInt("term", l.term)
+	return nil
+}
+
+func (l *leaderElection) level(zapcore.Level) zapcore.Level {
+	return noticeLevel
+}
+
+// LogLeaderElection logs as a NOTICE whether the process acquired role, such
+// as "leader", for term, or lost it.
+func LogLeaderElection(role string, acquired bool, term int) zapcore.Field {
+	return zap.Object("leaderElection", &leaderElection{
+		role:     role,
+		acquired: acquired,
+		term:     term,
+	})
+}
+
+// defaultQuotaWarningPercent is the percentage of its limit above which
+// LogQuota logs the usage of a quota as a WARNING, unless changed by
+// WithQuotaWarningPercent.
+const defaultQuotaWarningPercent = 80.0
+
+type quota struct {
+	resource       string
+	used           int64
+	limit          int64
+	warningPercent float64
+}
+
+func (q *quota) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("resource", q.resource)
+	e.AddInt64("used", q.used)
+	e.AddInt64("limit", q.limit)
+	return nil
+}
+
+func (q *quota) level(lv zapcore.Level) zapcore.Level {
+	if q.limit > 0 && float64(q.used)*100 >= q.warningPercent*float64(q.limit) && lv < zapcore.WarnLevel {
+		return zapcore.WarnLevel
+	}
+
+	return lv
+}
+
+type QuotaOption func(*quota)
+
+// WithQuotaWarningPercent makes LogQuota log the usage as a WARNING once it
+// reaches percent of the limit instead of 80%.
+func WithQuotaWarningPercent(percent float64) QuotaOption {
+	return func(q *quota) {
+		q.warningPercent = percent
+	}
+}
+
+// LogQuota logs how much of the quota of resource is used. The entry is at
+// least a WARNING once used reaches 80% of limit, or the percentage given to
+// WithQuotaWarningPercent. A limit of 0 or less is unlimited.
+func LogQuota(resource string, used, limit int64, opts ...QuotaOption) zapcore.Field {
+	q := &quota{
+		resource:       resource,
+		used:           used,
+		limit:          limit,
+		warningPercent: defaultQuotaWarningPercent,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return zap.Object("quota", q)
+}
+
+type spanChain struct {
+	parent  string
+	current string
+}
+
+func (s *spanChain) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	if s.parent != "" {
+		e.AddString("parent", s.parent)
+	}
+
+	e.AddString("current", s.current)
+	return nil
+}
+
+// LogSpanChain logs the ID of the current span of a nested operation along
+// with the ID of its parent, which is empty for a root span, so that the
+// chain can be followed without a trace. Unlike LogSpanID it doesn't need
+// the entry to have a trace.
+func LogSpanChain(parent, current string) zapcore.Field {
+	return zap.Object("spanChain", &spanChain{
+		parent:  parent,
+		current: current,
+	})
+}
+
+type idempotency struct {
+	key      string
+	replayed bool
+}
+
+func (i *idempotency) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("keyHash", hashString(i.key))
+	e.AddBool("replayed", i.replayed)
+	return nil
+}
+
+// LogIdempotency logs the handling of a request with an idempotency key, and
+// whether the stored response of an earlier request with the same key was
+// replayed. Only the hex SHA-256 hash of key is logged, since keys can be
+// used to replay requests.
+func LogIdempotency(key string, replayed bool) zapcore.Field {
+	return zap.Object("idempotency", &idempotency{
+		key:      key,
+		replayed: replayed,
+	})
+}
+
+// LogSynthetic sets the synthetic label of the entry to "true", for entries
+// caused by synthetic traffic such as uptime checks.
+func LogSynthetic() zapcore.Field {
+	return LogLabels(map[string]string{labelSynthetic: "true"})
+}
+
+type flagEval struct {
+	flag     string
+	value    interface{}
+	duration time.Duration
+	source   string
+}
+
+func (f *flagEval) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("flag", f.flag)
+
+	if err := e.AddReflected("value", f.value); err != nil {
+		return err
+	}
+
+	e.AddDuration("duration", f.duration)
+	e.AddString("source", f.source)
+	return nil
+}
+
+// LogFlagEval logs that evaluating the feature flag took d and returned value,
+// source being where it came from, such as "remote", "cache" or "default".
+func LogFlagEval(flag string, value interface{}, d time.Duration, source string) zapcore.Field {
+	return zap.Object("flagEval", &flagEval{
+		flag:     flag,
+		value:    value,
+		duration: d,
+		source:   source,
+	})
+}
+
+type inference struct {
+	model        string
+	inputTokens  int
+	outputTokens int
+	duration     time.Duration
+	err          error
+}
+
+func (i *inference) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("model", i.model)
+	e.AddInt("inputTokens", i.inputTokens)
+	e.AddInt("outputTokens", i.outputTokens)
+	e.AddDuration("duration", i.duration)
+
+	if i.err != nil {
+		e.AddString("error", i.err.Error())
+	}
+
+	return nil
+}
+
+// LogInference logs a call to a machine learning model, with the numbers of
+// tokens it took and returned, for cost and latency tracking.
+func LogInference(model string, inputTokens, outputTokens int, d time.Duration, err error) zapcore.Field {
+	return zap.Object("inference", &inference{
+		model:        model,
+		inputTokens:  inputTokens,
+		outputTokens: outputTokens,
+		duration:     d,
+		err:          err,
+	})
+}
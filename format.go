@@ -0,0 +1,95 @@
+package stackdriver
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldFormatter renders a field value for the message of an entry.
+type FieldFormatter func(field zapcore.Field) string
+
+// fieldFormatterRegistry is never changed once stored in fieldFormatters, so
+// that appending fields to messages doesn't take a lock. Registering copies
+// it instead.
+type fieldFormatterRegistry struct {
+	types map[zapcore.FieldType]FieldFormatter
+	keys  map[string]FieldFormatter
+}
+
+func (r *fieldFormatterRegistry) clone() *fieldFormatterRegistry {
+	clone := &fieldFormatterRegistry{
+		types: make(map[zapcore.FieldType]FieldFormatter, len(r.types)),
+		keys:  make(map[string]FieldFormatter, len(r.keys)),
+	}
+
+	for t, fn := range r.types {
+		clone.types[t] = fn
+	}
+
+	for key, fn := range r.keys {
+		clone.keys[key] = fn
+	}
+
+	return clone
+}
+
+var (
+	fieldFormatters     atomic.Value
+	fieldFormattersLock sync.Mutex
+)
+
+func init() {
+	fieldFormatters.Store(&fieldFormatterRegistry{})
+}
+
+// RegisterFieldFormatter makes fields of type t be rendered by fn when they
+// are appended to the message. A nil fn restores the default rendering.
+func RegisterFieldFormatter(t zapcore.FieldType, fn FieldFormatter) {
+	fieldFormattersLock.Lock()
+	defer fieldFormattersLock.Unlock()
+
+	r := fieldFormatters.Load().(*fieldFormatterRegistry).clone()
+
+	if fn == nil {
+		delete(r.types, t)
+	} else {
+		r.types[t] = fn
+	}
+
+	fieldFormatters.Store(r)
+}
+
+// RegisterFieldKeyFormatter makes fields named key be rendered by fn when
+// they are appended to the message. It takes precedence over formatters
+// registered by type. A nil fn restores the default rendering.
+func RegisterFieldKeyFormatter(key string, fn FieldFormatter) {
+	fieldFormattersLock.Lock()
+	defer fieldFormattersLock.Unlock()
+
+	r := fieldFormatters.Load().(*fieldFormatterRegistry).clone()
+
+	if fn == nil {
+		delete(r.keys, key)
+	} else {
+		r.keys[key] = fn
+	}
+
+	fieldFormatters.Store(r)
+}
+
+func lookupFieldFormatter(field zapcore.Field) (FieldFormatter, bool) {
+	r := fieldFormatters.Load().(*fieldFormatterRegistry)
+
+	if len(r.keys) == 0 && len(r.types) == 0 {
+		return nil, false
+	}
+
+	if fn, ok := r.keys[field.Key]; ok {
+		return fn, true
+	}
+
+	fn, ok := r.types[field.Type]
+	return fn, ok
+}
@@ -0,0 +1,43 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRegisterFieldFormatter(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := newCore(writer)
+	core.MessageFields = true
+	logger := zap.New(core)
+
+	RegisterFieldFormatter(zapcore.Int64Type, func(field zapcore.Field) string {
+		return "#" + strconv.FormatInt(field.Integer, 16)
+	})
+	defer RegisterFieldFormatter(zapcore.Int64Type, nil)
+
+	RegisterFieldKeyFormatter("secret", func(field zapcore.Field) string {
+		return "***"
+	})
+	defer RegisterFieldKeyFormatter("secret", nil)
+
+	logger.Info("test",
+		zap.Int64("foo", 255),
+		zap.Int64("secret", 42),
+		zap.String("bar", "baz"),
+	)
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "test foo=#ff secret=*** bar=baz", actual.Message)
+
+	RegisterFieldFormatter(zapcore.Int64Type, nil)
+	assert.Equal(t, "255", newCore(writer).fieldValueToString(zap.Int64("foo", 255)))
+}
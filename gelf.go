@@ -0,0 +1,184 @@
+package stackdriver
+
+import (
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const gelfVersion = "1.1"
+
+// logLevelGELF maps levels to the syslog severities GELF uses.
+var logLevelGELF = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	noticeLevel:         5,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  1,
+	zapcore.FatalLevel:  0,
+}
+
+// GELFEncoderConfig is the encoder config used by NewGELFEncoder.
+var GELFEncoderConfig = zapcore.EncoderConfig{
+	TimeKey:        "timestamp",
+	LevelKey:       "level",
+	NameKey:        "_logger",
+	CallerKey:      "_caller",
+	MessageKey:     "short_message",
+	StacktraceKey:  "full_message",
+	LineEnding:     zapcore.DefaultLineEnding,
+	EncodeLevel:    EncodeGELFLevel,
+	EncodeTime:     zapcore.EpochTimeEncoder,
+	EncodeDuration: zapcore.MillisDurationEncoder,
+	EncodeCaller:   zapcore.ShortCallerEncoder,
+}
+
+// EncodeGELFLevel writes the syslog severity of lv as a number.
+func EncodeGELFLevel(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level, ok := logLevelGELF[lv]; ok {
+		enc.AppendInt(level)
+		return
+	}
+
+	enc.AppendInt(logLevelGELF[zapcore.InfoLevel])
+}
+
+// NewGELFEncoder returns an encoder writing entries as GELF messages sent by
+// host, for Graylog. Fields are written as additional fields, with their keys
+// prefixed with an underscore. It's meant for a separate core, teed with the
+// one writing to Stackdriver.
+func NewGELFEncoder(host string) zapcore.Encoder {
+	enc := zapcore.NewJSONEncoder(GELFEncoderConfig)
+	enc.AddString("version", gelfVersion)
+	enc.AddString("host", host)
+
+	return gelfEncoder{enc}
+}
+
+// gelfEncoder prefixes the keys of the fields it encodes at the root of the
+// message with an underscore.
+type gelfEncoder struct {
+	zapcore.Encoder
+}
+
+func gelfKey(key string) string {
+	return "_" + key
+}
+
+func (e gelfEncoder) Clone() zapcore.Encoder {
+	return gelfEncoder{e.Encoder.Clone()}
+}
+
+func (e gelfEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	output := make([]zapcore.Field, len(fields))
+
+	for i, f := range fields {
+		f.Key = gelfKey(f.Key)
+		output[i] = f
+	}
+
+	return e.Encoder.EncodeEntry(entry, output)
+}
+
+func (e gelfEncoder) AddArray(key string, m zapcore.ArrayMarshaler) error {
+	return e.Encoder.AddArray(gelfKey(key), m)
+}
+
+func (e gelfEncoder) AddObject(key string, m zapcore.ObjectMarshaler) error {
+	return e.Encoder.AddObject(gelfKey(key), m)
+}
+
+func (e gelfEncoder) AddBinary(key string, value []byte) {
+	e.Encoder.AddBinary(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddByteString(key string, value []byte) {
+	e.Encoder.AddByteString(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddBool(key string, value bool) {
+	e.Encoder.AddBool(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddComplex128(key string, value complex128) {
+	e.Encoder.AddComplex128(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddComplex64(key string, value complex64) {
+	e.Encoder.AddComplex64(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddDuration(key string, value time.Duration) {
+	e.Encoder.AddDuration(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddFloat64(key string, value float64) {
+	e.Encoder.AddFloat64(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddFloat32(key string, value float32) {
+	e.Encoder.AddFloat32(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddInt(key string, value int) {
+	e.Encoder.AddInt(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddInt64(key string, value int64) {
+	e.Encoder.AddInt64(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddInt32(key string, value int32) {
+	e.Encoder.AddInt32(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddInt16(key string, value int16) {
+	e.Encoder.AddInt16(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddInt8(key string, value int8) {
+	e.Encoder.AddInt8(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddString(key, value string) {
+	e.Encoder.AddString(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddTime(key string, value time.Time) {
+	e.Encoder.AddTime(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUint(key string, value uint) {
+	e.Encoder.AddUint(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUint64(key string, value uint64) {
+	e.Encoder.AddUint64(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUint32(key string, value uint32) {
+	e.Encoder.AddUint32(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUint16(key string, value uint16) {
+	e.Encoder.AddUint16(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUint8(key string, value uint8) {
+	e.Encoder.AddUint8(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddUintptr(key string, value uintptr) {
+	e.Encoder.AddUintptr(gelfKey(key), value)
+}
+
+func (e gelfEncoder) AddReflected(key string, value interface{}) error {
+	return e.Encoder.AddReflected(gelfKey(key), value)
+}
+
+func (e gelfEncoder) OpenNamespace(key string) {
+	e.Encoder.OpenNamespace(gelfKey(key))
+}
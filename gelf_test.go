@@ -0,0 +1,53 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewGELFEncoder(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := zapcore.NewCore(NewGELFEncoder("web-1"), zapcore.AddSync(writer), zapcore.DebugLevel)
+	logger := zap.New(core).Named("api").With(zap.String("foo", "bar"))
+
+	logger.Warn("slow request", zap.Object("queue", &queueStats{name: "emails", depth: 2}))
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "1.1", actual["version"])
+	assert.Equal(t, "web-1", actual["host"])
+	assert.Equal(t, "slow request", actual["short_message"])
+	assert.Equal(t, float64(4), actual["level"])
+	assert.IsType(t, float64(0), actual["timestamp"])
+	assert.Equal(t, "api", actual["_logger"])
+	assert.Equal(t, "bar", actual["_foo"])
+	assert.Equal(t, map[string]interface{}{
+		"name":      "emails",
+		"depth":     float64(2),
+		"inFlight":  float64(0),
+		"oldestAge": float64(0),
+	}, actual["_queue"])
+	assert.NotContains(t, actual, "foo")
+	assert.NotContains(t, actual, "_version")
+}
+
+func TestEncodeGELFLevel(t *testing.T) {
+	for lv, level := range map[zapcore.Level]int{
+		zapcore.DebugLevel: 7,
+		noticeLevel:        5,
+		zapcore.ErrorLevel: 3,
+		zapcore.FatalLevel: 0,
+		zapcore.Level(-10): 6,
+	} {
+		enc := new(PrimitiveArrayEncoder)
+		enc.On("AppendInt", level).Once()
+		EncodeGELFLevel(lv, enc)
+		enc.AssertExpectations(t)
+	}
+}
@@ -1,8 +1,17 @@
 module github.com/pablote/zap-stackdriver
 
-go 1.14
+go 1.18
 
 require (
 	github.com/stretchr/testify v1.4.0
 	go.uber.org/zap v1.15.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
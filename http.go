@@ -0,0 +1,375 @@
+package stackdriver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultHeaders are the request headers logged by WithHeaders when no names
+// are given. Headers which may carry credentials are left out.
+var DefaultHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Content-Length",
+	"Content-Type",
+	"Origin",
+	"X-Forwarded-For",
+	"X-Forwarded-Proto",
+	"X-Request-Id",
+}
+
+type httpRequestOptions struct {
+	headers []string
+	tls     bool
+}
+
+type HTTPRequestOption func(*httpRequestOptions)
+
+// WithHeaders includes the request headers with the given names, or
+// DefaultHeaders if none are given. Other headers are never logged.
+func WithHeaders(names ...string) HTTPRequestOption {
+	if len(names) == 0 {
+		names = DefaultHeaders
+	}
+
+	return func(o *httpRequestOptions) {
+		o.headers = names
+	}
+}
+
+// WithTLS includes the TLS version and cipher suite of requests received over
+// TLS.
+func WithTLS() HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.tls = true
+	}
+}
+
+// NewHTTPRequest returns the HTTPRequest describing r. RequestSize is the size
+// of its body, when known. ResponseStatusCode is left to be filled in once the
+// response is written.
+func NewHTTPRequest(r *http.Request, opts ...HTTPRequestOption) *HTTPRequest {
+	options := &httpRequestOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	req := &HTTPRequest{
+		Method:    r.Method,
+		URL:       requestURL(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+		RemoteIP:  remoteIP(r),
+	}
+
+	if r.ContentLength > 0 {
+		req.RequestSize = r.ContentLength
+	}
+
+	for _, name := range options.headers {
+		if values := r.Header.Values(name); len(values) > 0 {
+			if req.Headers == nil {
+				req.Headers = map[string]string{}
+			}
+
+			req.Headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+		}
+	}
+
+	if options.tls && r.TLS != nil {
+		req.TLS = &TLSInfo{
+			Version:     tlsVersionName(r.TLS.Version),
+			CipherSuite: tls.CipherSuiteName(r.TLS.CipherSuite),
+		}
+	}
+
+	return req
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return fmt.Sprintf("0x%04X", version)
+}
+
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// DefaultLatencyBuckets are the bucket boundaries used by WithLatencyBuckets
+// when none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+const (
+	labelLatencyBucket = "latencyBucket"
+	labelPriority      = "priority"
+	labelSynthetic     = "synthetic"
+)
+
+type middleware struct {
+	logger          *zap.Logger
+	level           zapcore.Level
+	latencyBuckets  []time.Duration
+	priorityHeader  string
+	syntheticHeader string
+	costEstimator   func(*HTTPRequest) int64
+	traceProject    string
+}
+
+type MiddlewareOption func(*middleware)
+
+// WithAccessLogSeverity sets the level the access entries are logged at,
+// which defaults to InfoLevel.
+func WithAccessLogSeverity(lv zapcore.Level) MiddlewareOption {
+	return func(m *middleware) {
+		m.level = lv
+	}
+}
+
+// WithLatencyBuckets adds the latencyBucket label to the access entries, for
+// log-based metrics. Given the sorted boundaries 100ms and 1s, its value is one
+// of "<100ms", "<1s" and ">=1s". DefaultLatencyBuckets are used when no
+// boundaries are given.
+func WithLatencyBuckets(bounds ...time.Duration) MiddlewareOption {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+
+	return func(m *middleware) {
+		m.latencyBuckets = bounds
+	}
+}
+
+// WithPriorityHeader adds the priority label to the access entries of the
+// requests with the header name, set to its value, so that the entries can be
+// filtered by traffic class.
+func WithPriorityHeader(name string) MiddlewareOption {
+	return func(m *middleware) {
+		m.priorityHeader = name
+	}
+}
+
+// WithSyntheticHeader adds the synthetic label to the access entries of the
+// requests with the header name, such as those of uptime checks, so that
+// log-based metrics can leave them out. See LogSynthetic.
+func WithSyntheticHeader(name string) MiddlewareOption {
+	return func(m *middleware) {
+		m.syntheticHeader = name
+	}
+}
+
+// WithCostEstimator adds the cost of each request estimated by estimate, in
+// millionths of a currency unit, to its access entry as costMicros.
+func WithCostEstimator(estimate func(*HTTPRequest) int64) MiddlewareOption {
+	return func(m *middleware) {
+		m.costEstimator = estimate
+	}
+}
+
+// WithTraceProject adds the trace of each request, read by
+// RequestTraceFields, to its access entry so that the entry is correlated
+// with the trace in projectID.
+func WithTraceProject(projectID string) MiddlewareOption {
+	return func(m *middleware) {
+		m.traceProject = projectID
+	}
+}
+
+// Middleware returns a middleware which logs an access entry with logger for
+// every request once it has been handled. The handler can retrieve the
+// request-scoped logger, with the trace of WithTraceProject, with
+// FromContext.
+func Middleware(logger *zap.Logger, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{
+		logger: logger,
+		level:  zapcore.InfoLevel,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			logger := m.requestLogger(r)
+			next.ServeHTTP(rw, r.WithContext(WithContext(r.Context(), logger)))
+			m.log(r, logger, rw, time.Since(start))
+		})
+	}
+}
+
+func (m *middleware) requestLogger(r *http.Request) *zap.Logger {
+	if m.traceProject == "" {
+		return m.logger
+	}
+
+	return m.logger.With(RequestTraceFields(r, m.traceProject)...)
+}
+
+func (m *middleware) log(r *http.Request, logger *zap.Logger, rw *responseWriter, latency time.Duration) {
+	ce := logger.Check(m.level, r.Method+" "+r.URL.RequestURI())
+
+	if ce == nil {
+		return
+	}
+
+	req := NewHTTPRequest(r)
+	req.ResponseStatusCode = rw.Status()
+	req.Latency = latency
+	req.ResponseSize = rw.size
+	fields := []zapcore.Field{LogHTTPRequest(req)}
+
+	if m.costEstimator != nil {
+		fields = append(fields, zap.Int64("costMicros", m.costEstimator(req)))
+	}
+
+	labels := map[string]string{}
+
+	if len(m.latencyBuckets) > 0 {
+		labels[labelLatencyBucket] = latencyBucket(latency, m.latencyBuckets)
+	}
+
+	if m.priorityHeader != "" {
+		if priority := r.Header.Get(m.priorityHeader); priority != "" {
+			labels[labelPriority] = priority
+		}
+	}
+
+	if m.syntheticHeader != "" && r.Header.Get(m.syntheticHeader) != "" {
+		labels[labelSynthetic] = "true"
+	}
+
+	if len(labels) > 0 {
+		fields = append(fields, LogLabels(labels))
+	}
+
+	ce.Write(fields...)
+}
+
+func latencyBucket(latency time.Duration, bounds []time.Duration) string {
+	for _, b := range bounds {
+		if latency < b {
+			return "<" + b.String()
+		}
+	}
+
+	return ">=" + bounds[len(bounds)-1].String()
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection, such as to upgrade it to a WebSocket, if
+// the underlying writer supports it. The access entry then has the
+// 101 Switching Protocols status unless the handler wrote another one.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer to http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code of the response, which is 200 when the
+// handler didn't write anything.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
@@ -0,0 +1,336 @@
+package stackdriver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewHTTPRequest(t *testing.T) {
+	t.Run("Basic", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/foo?bar=baz", nil)
+		r.RemoteAddr = "1.2.3.4:5678"
+		r.Header.Set("User-Agent", "agent")
+		r.Header.Set("Referer", "http://example.com/")
+
+		assert.Equal(t, &HTTPRequest{
+			Method:    "GET",
+			URL:       "http://example.com/foo?bar=baz",
+			UserAgent: "agent",
+			Referrer:  "http://example.com/",
+			RemoteIP:  "1.2.3.4",
+		}, NewHTTPRequest(r))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/foo", nil)
+		r.TLS = &tls.ConnectionState{}
+
+		req := NewHTTPRequest(r)
+		assert.Equal(t, "https://example.com/foo", req.URL)
+		assert.Empty(t, req.UserAgent)
+		assert.Empty(t, req.Referrer)
+	})
+}
+
+func TestNewHTTPRequest_WithHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Set("X-Custom", "foo")
+
+	t.Run("Default", func(t *testing.T) {
+		req := NewHTTPRequest(r, WithHeaders())
+		assert.Equal(t, map[string]string{
+			"Accept":       "text/html, application/json",
+			"Content-Type": "application/json",
+		}, req.Headers)
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		req := NewHTTPRequest(r, WithHeaders("x-custom", "X-Missing"))
+		assert.Equal(t, map[string]string{
+			"X-Custom": "foo",
+		}, req.Headers)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Nil(t, NewHTTPRequest(r).Headers)
+	})
+}
+
+func TestNewHTTPRequest_WithTLS(t *testing.T) {
+	t.Run("TLS", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.TLS = &tls.ConnectionState{
+			Version:     tls.VersionTLS13,
+			CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+		}
+
+		assert.Equal(t, &TLSInfo{
+			Version:     "TLS 1.3",
+			CipherSuite: "TLS_AES_128_GCM_SHA256",
+		}, NewHTTPRequest(r, WithTLS()).TLS)
+		assert.Nil(t, NewHTTPRequest(r).TLS)
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		assert.Nil(t, NewHTTPRequest(r, WithTLS()).TLS)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		defer writer.Reset()
+
+		r := httptest.NewRequest("POST", "/foo?bar=baz", nil)
+		r.RemoteAddr = "1.2.3.4:5678"
+		rec := httptest.NewRecorder()
+		Middleware(logger)(handler).ServeHTTP(rec, r)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "ok", rec.Body.String())
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "INFO", actual.Severity)
+		assert.Equal(t, "POST /foo?bar=baz", actual.Message)
+		assert.Equal(t, &HTTPRequest{
+			Method:             "POST",
+			URL:                "http://example.com/foo?bar=baz",
+			ResponseStatusCode: http.StatusCreated,
+			RemoteIP:           "1.2.3.4",
+			ResponseSize:       2,
+		}, actual.Context.HTTPRequest)
+		assert.Regexp(t, `"latency":"\d+\.\d+s"`, writer.String())
+	})
+
+	t.Run("Access log severity", func(t *testing.T) {
+		defer writer.Reset()
+
+		r := httptest.NewRequest("GET", "/", nil)
+		Middleware(logger, WithAccessLogSeverity(zapcore.DebugLevel))(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "DEBUG", actual.Severity)
+	})
+
+	t.Run("Implicit status", func(t *testing.T) {
+		defer writer.Reset()
+
+		r := httptest.NewRequest("GET", "/", nil)
+		Middleware(logger)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+		Middleware(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+
+		dec := json.NewDecoder(writer)
+
+		var notFound, empty logEntry
+		require.Nil(t, dec.Decode(&notFound))
+		require.Nil(t, dec.Decode(&empty))
+		assert.Equal(t, http.StatusNotFound, notFound.Context.HTTPRequest.ResponseStatusCode)
+		assert.Equal(t, http.StatusOK, empty.Context.HTTPRequest.ResponseStatusCode)
+	})
+}
+
+func TestMiddleware_WithLatencyBuckets(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	Middleware(logger, WithLatencyBuckets())(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"latencyBucket": "<100ms"}, actual.Labels)
+}
+
+func TestMiddleware_WithPriorityHeader(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	handler := Middleware(logger, WithPriorityHeader("X-Priority"))(http.NotFoundHandler())
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Priority", "batch")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"priority": "batch"}, actual.Labels)
+
+	writer.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	actual = logEntry{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Empty(t, actual.Labels)
+}
+
+func TestMiddleware_WithSyntheticHeader(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	handler := Middleware(logger, WithSyntheticHeader("X-Synthetic"))(http.NotFoundHandler())
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Synthetic", "uptime-check")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"synthetic": "true"}, actual.Labels)
+
+	writer.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	actual = logEntry{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Empty(t, actual.Labels)
+}
+
+func TestMiddleware_WithCostEstimator(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	estimate := func(req *HTTPRequest) int64 {
+		return 10 + req.ResponseSize
+	}
+	handler := Middleware(logger, WithCostEstimator(estimate))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	var actual struct {
+		logEntry
+
+		CostMicros int64 `json:"costMicros"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, int64(15), actual.CostMicros)
+}
+
+func TestMiddleware_WithTraceProject(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+	handler := Middleware(logger, WithTraceProject("foo"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("handling")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", "00-"+testTraceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	dec := json.NewDecoder(writer)
+
+	for _, message := range []string{"handling", "GET /"} {
+		var actual logEntry
+		require.Nil(t, dec.Decode(&actual))
+		assert.Equal(t, message, actual.Message)
+		assert.Equal(t, "projects/foo/traces/"+testTraceID, actual.Trace)
+		assert.Equal(t, "00f067aa0ba902b7", actual.SpanID)
+		assert.True(t, actual.TraceSampled)
+	}
+
+	writer.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	dec = json.NewDecoder(writer)
+
+	for i := 0; i < 2; i++ {
+		var actual logEntry
+		require.Nil(t, dec.Decode(&actual))
+		assert.Empty(t, actual.Trace)
+	}
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	writer := &lockedBuffer{}
+	logger := zap.New(newCore(writer))
+	server := httptest.NewServer(Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.Nil(t, err)
+	defer conn.Close()
+
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	// Polling by hand, since Eventually can panic after returning in this
+	// version of testify.
+	for deadline := time.Now().Add(time.Second); writer.String() == ""; {
+		require.True(t, time.Now().Before(deadline), "no access entry logged")
+		time.Sleep(time.Millisecond)
+	}
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal([]byte(writer.String()), &actual))
+	assert.Equal(t, http.StatusSwitchingProtocols, actual.Context.HTTPRequest.ResponseStatusCode)
+}
+
+func TestMiddleware_HijackNotSupported(t *testing.T) {
+	logger := zap.New(newCore(bytes.NewBuffer(nil)))
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.Equal(t, http.ErrNotSupported, err)
+		assert.Equal(t, http.ErrNotSupported, w.(http.Pusher).Push("/foo", nil))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestLatencyBucket(t *testing.T) {
+	bounds := []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, time.Second}
+	tests := []struct {
+		Latency  time.Duration
+		Expected string
+	}{
+		{Latency: 0, Expected: "<100ms"},
+		{Latency: 99 * time.Millisecond, Expected: "<100ms"},
+		{Latency: 100 * time.Millisecond, Expected: "<500ms"},
+		{Latency: 999 * time.Millisecond, Expected: "<1s"},
+		{Latency: time.Second, Expected: ">=1s"},
+		{Latency: time.Minute, Expected: ">=1s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Latency.String(), func(t *testing.T) {
+			assert.Equal(t, test.Expected, latencyBucket(test.Latency, bounds))
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package stackdriver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ParseLevel parses the name of a level of zap, such as "warn", or a severity
+// of Cloud Logging, such as "WARNING", regardless of case. NOTICE is parsed
+// as InfoLevel, since the levels enabled by a Core are those of zap.
+func ParseLevel(text string) (zapcore.Level, error) {
+	text = strings.TrimSpace(text)
+
+	if lv, ok := severityLevel[strings.ToUpper(text)]; ok && lv != defaultLevel {
+		if lv == noticeLevel {
+			return zapcore.InfoLevel, nil
+		}
+
+		return lv, nil
+	}
+
+	var lv zapcore.Level
+
+	if text == "" {
+		return lv, errors.New("empty level")
+	}
+
+	if err := lv.UnmarshalText([]byte(strings.ToLower(text))); err != nil {
+		return lv, fmt.Errorf("unknown level %q", text)
+	}
+
+	return lv, nil
+}
+
+// LevelFromEnv returns an AtomicLevel, to be given to NewJSONCore or
+// NewSplitCore and changed at runtime, set to the level parsed by ParseLevel
+// from the environment variable name, or to fallback if it isn't set or
+// can't be parsed.
+func LevelFromEnv(name string, fallback zapcore.Level) zap.AtomicLevel {
+	level := zap.NewAtomicLevelAt(fallback)
+
+	if lv, err := ParseLevel(os.Getenv(name)); err == nil {
+		level.SetLevel(lv)
+	}
+
+	return level
+}
+
+// LevelHandler returns the HTTP handler of level, which is that of zap except
+// that it also accepts severities: GET returns the current level as
+// {"level":"info"} and PUT with a body such as {"level":"WARNING"} changes
+// it.
+func LevelHandler(level zap.AtomicLevel) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			level.ServeHTTP(w, r)
+			return
+		}
+
+		var req struct {
+			Level string `json:"level"`
+		}
+
+		enc := json.NewEncoder(w)
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			enc.Encode(map[string]string{"error": "Request body must be well-formed JSON: " + err.Error()})
+			return
+		}
+
+		lv, err := ParseLevel(req.Level)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			enc.Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		level.SetLevel(lv)
+		enc.Encode(map[string]zapcore.Level{"level": lv})
+	})
+}
+
+// defaultWatchInterval is the interval of WatchLevelFile when the given one
+// isn't positive.
+const defaultWatchInterval = 10 * time.Second
+
+// WatchLevelFile sets level to the level written in the file at path, such as
+// one mounted from a ConfigMap on GKE, and then reads the file again every
+// interval, or every 10 seconds if it isn't positive, until ctx is done.
+// Changes are logged with logger by LogConfigReload. The level is left as it
+// is while the file is missing or can't be parsed, which is logged as a
+// warning once per content.
+func WatchLevelFile(ctx context.Context, logger *zap.Logger, level zap.AtomicLevel, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	var last string
+
+	read := func() {
+		b, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			if !os.IsNotExist(err) && last != err.Error() {
+				logger.Warn("can't read level file", zap.String("path", path), zap.Error(err))
+			}
+
+			last = err.Error()
+			return
+		}
+
+		text := strings.TrimSpace(string(b))
+
+		if text == last {
+			return
+		}
+
+		last = text
+		lv, err := ParseLevel(text)
+
+		if err != nil {
+			logger.Warn("can't parse level file", zap.String("path", path), zap.Error(err))
+			return
+		}
+
+		if lv != level.Level() {
+			level.SetLevel(lv)
+			logger.Info("level changed", LogConfigReload("file:"+path, map[string]interface{}{"level": lv.String()}))
+		}
+	}
+
+	read()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				read()
+			}
+		}
+	}()
+}
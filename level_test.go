@@ -0,0 +1,143 @@
+package stackdriver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevel(t *testing.T) {
+	for text, expected := range map[string]zapcore.Level{
+		"debug":     zapcore.DebugLevel,
+		"INFO":      zapcore.InfoLevel,
+		"notice":    zapcore.InfoLevel,
+		"warn":      zapcore.WarnLevel,
+		"WARNING":   zapcore.WarnLevel,
+		" error\n":  zapcore.ErrorLevel,
+		"dpanic":    zapcore.DPanicLevel,
+		"CRITICAL":  zapcore.DPanicLevel,
+		"alert":     zapcore.PanicLevel,
+		"EMERGENCY": zapcore.FatalLevel,
+	} {
+		lv, err := ParseLevel(text)
+
+		require.Nil(t, err, text)
+		assert.Equal(t, expected, lv, text)
+	}
+
+	for _, text := range []string{"", "DEFAULT", "verbose"} {
+		_, err := ParseLevel(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestLevelFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "WARNING")
+	assert.Equal(t, zapcore.WarnLevel, LevelFromEnv("LOG_LEVEL", zapcore.InfoLevel).Level())
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	assert.Equal(t, zapcore.InfoLevel, LevelFromEnv("LOG_LEVEL", zapcore.InfoLevel).Level())
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	srv := httptest.NewServer(LevelHandler(level))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"level":"WARNING"}`))
+	require.Nil(t, err)
+
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+
+	req, err = http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"level":"verbose"}`))
+	require.Nil(t, err)
+
+	res, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+
+	res, err = http.Get(srv.URL)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	var actual struct {
+		Level string `json:"level"`
+	}
+
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&actual))
+	assert.Equal(t, "warn", actual.Level)
+}
+
+func TestWatchLevelFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "level")
+	require.Nil(t, ioutil.WriteFile(path, []byte("debug\n"), 0o644))
+
+	writer := &lockedBuffer{}
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	WatchLevelFile(ctx, zap.New(newCore(writer)), level, path, time.Millisecond)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+
+	require.Nil(t, ioutil.WriteFile(path, []byte("ERROR"), 0o644))
+	// Polling by hand, since Eventually can panic after returning in this
+	// version of testify.
+	for deadline := time.Now().Add(time.Second); strings.Count(writer.String(), "\n") < 2; {
+		require.True(t, time.Now().Before(deadline), "level change not logged")
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, zapcore.ErrorLevel, level.Level())
+
+	cancel()
+
+	var changed []interface{}
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual struct {
+			logEntry
+
+			ConfigReload map[string]interface{} `json:"configReload"`
+		}
+
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Equal(t, "NOTICE", actual.Severity)
+		assert.Equal(t, "file:"+path, actual.ConfigReload["source"])
+		changed = append(changed, actual.ConfigReload["changed"])
+	}
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"level": "debug"},
+		map[string]interface{}{"level": "error"},
+	}, changed)
+}
+
+func TestWatchLevelFile_DefaultInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "level")
+	require.Nil(t, ioutil.WriteFile(path, []byte("warn"), 0o644))
+
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	WatchLevelFile(ctx, zap.NewNop(), level, path, 0)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+}
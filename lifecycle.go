@@ -0,0 +1,79 @@
+package stackdriver
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const labelLifecycle = "lifecycle"
+
+// LogStartup logs that the process started with the NOTICE severity and the
+// lifecycle label set to "startup", reporting its caller as the caller of the
+// entry.
+func LogStartup(logger *zap.Logger, fields ...zapcore.Field) {
+	logLifecycle(logger, "startup", fields)
+}
+
+// LogShutdown logs that the process is shutting down with the NOTICE severity
+// and the lifecycle label set to "shutdown".
+func LogShutdown(logger *zap.Logger, fields ...zapcore.Field) {
+	logLifecycle(logger, "shutdown", fields)
+}
+
+func logLifecycle(logger *zap.Logger, event string, fields []zapcore.Field) {
+	attrs := &entryAttributes{
+		entryLevel:  noticeLevel,
+		entryLabels: map[string]string{labelLifecycle: event},
+	}
+
+	logger.WithOptions(zap.AddCallerSkip(2)).Info(event, append(fields[:len(fields):len(fields)], attrs.field())...)
+}
+
+type drain struct {
+	phase    string
+	inFlight int
+	duration time.Duration
+}
+
+func (d *drain) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("phase", d.phase)
+	e.AddInt("inFlight", d.inFlight)
+
+	if d.phase == "complete" {
+		e.AddDuration("duration", d.duration)
+	}
+
+	return nil
+}
+
+// LogDrainStart logs that the process stopped accepting work when shutting
+// down, with inFlight requests or jobs left to finish. Like the other drain
+// helpers, it reports its caller as the caller of the entry.
+func LogDrainStart(logger *zap.Logger, inFlight int) {
+	logDrain(logger, &drain{phase: "start", inFlight: inFlight})
+}
+
+// LogDrainProgress logs how many requests or jobs are left to finish while
+// draining, such as every second.
+func LogDrainProgress(logger *zap.Logger, inFlight int) {
+	logDrain(logger, &drain{phase: "progress", inFlight: inFlight})
+}
+
+// LogDrainComplete logs that draining took d, inFlight being the number of
+// requests or jobs which didn't finish in time.
+func LogDrainComplete(logger *zap.Logger, inFlight int, d time.Duration) {
+	logDrain(logger, &drain{phase: "complete", inFlight: inFlight, duration: d})
+}
+
+// logDrain logs d with the NOTICE severity and the lifecycle label set to
+// "drain".
+func logDrain(logger *zap.Logger, d *drain) {
+	attrs := &entryAttributes{
+		entryLevel:  noticeLevel,
+		entryLabels: map[string]string{labelLifecycle: "drain"},
+	}
+
+	logger.WithOptions(zap.AddCallerSkip(2)).Info("drain "+d.phase, zap.Object("drain", d), attrs.field())
+}
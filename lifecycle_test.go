@@ -0,0 +1,113 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogStartup(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	LogStartup(zap.New(newCore(writer)), zap.String("version", "1.2.3"))
+
+	var actual struct {
+		logEntry
+
+		Version string `json:"version"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "NOTICE", actual.Severity)
+	assert.Equal(t, "startup", actual.Message)
+	assert.Equal(t, "1.2.3", actual.Version)
+	assert.Equal(t, map[string]string{"lifecycle": "startup"}, actual.Labels)
+}
+
+func TestLogStartup_Caller(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer), zap.AddCaller())
+	fields := make([]zapcore.Field, 1, 2)
+	fields[0] = zap.String("version", "1.2.3")
+
+	LogStartup(logger, fields...)
+	assert.Equal(t, zapcore.Field{}, fields[:2][1])
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual struct {
+			Caller string `json:"caller"`
+		}
+
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Contains(t, actual.Caller, "lifecycle_test.go")
+	}
+}
+
+func TestLogShutdown(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	LogShutdown(zap.New(newCore(writer)))
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "NOTICE", actual.Severity)
+	assert.Equal(t, "shutdown", actual.Message)
+	assert.Equal(t, map[string]string{"lifecycle": "shutdown"}, actual.Labels)
+
+	writer.Reset()
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+	LogShutdown(zap.New(NewCore(zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.WarnLevel))))
+	assert.Empty(t, writer.String())
+}
+
+func TestLogDrain(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	LogDrainStart(logger, 3)
+	LogDrainProgress(logger, 1)
+	LogDrainComplete(logger, 0, 2*time.Second)
+
+	type drainEntry struct {
+		logEntry
+
+		Drain map[string]interface{} `json:"drain"`
+	}
+
+	var entries []drainEntry
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual drainEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Equal(t, "NOTICE", actual.Severity)
+		assert.Equal(t, map[string]string{"lifecycle": "drain"}, actual.Labels)
+		entries = append(entries, actual)
+	}
+
+	require.Len(t, entries, 3)
+
+	writer.Reset()
+	LogDrainStart(zap.New(newCore(writer), zap.AddCaller()), 1)
+
+	var actual struct {
+		Caller string `json:"caller"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Contains(t, actual.Caller, "lifecycle_test.go")
+	assert.Equal(t, "drain start", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"phase": "start", "inFlight": float64(3)}, entries[0].Drain)
+	assert.Equal(t, "drain progress", entries[1].Message)
+	assert.Equal(t, map[string]interface{}{"phase": "progress", "inFlight": float64(1)}, entries[1].Drain)
+	assert.Equal(t, "drain complete", entries[2].Message)
+	assert.Equal(t, map[string]interface{}{
+		"phase":    "complete",
+		"inFlight": float64(0),
+		"duration": float64(2000),
+	}, entries[2].Drain)
+}
@@ -0,0 +1,162 @@
+package stackdriver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	labelRegion = "region"
+	labelZone   = "zone"
+
+	defaultMetadataHost = "169.254.169.254"
+)
+
+// location is where the workload runs, as told by the metadata server.
+type location struct {
+	region string
+	zone   string
+}
+
+// locationCache only keeps the location once it was read, so that a
+// cancelled context or a failing metadata server doesn't leave the process
+// without location labels.
+var locationCache struct {
+	mu  sync.Mutex
+	loc *location
+}
+
+// metadataClient bounds the requests to the metadata server, which may not
+// answer at all outside of GCP or behind a firewall.
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// metadataUnreachable is set once a request to the metadata server failed
+// without a response, after which it isn't asked again.
+var metadataUnreachable int32
+
+// WithLocationLabelsFromMetadata labels every entry with the region and zone
+// the workload runs in on GCE, GKE or Cloud Run, read from the metadata
+// server. The metadata server is only asked until it answered, and not
+// waited for more than 2 seconds, no label being added if it can't be
+// reached. Its address can be changed with the GCE_METADATA_HOST environment
+// variable.
+func WithLocationLabelsFromMetadata(ctx context.Context) Option {
+	return func(c *Core) {
+		loc := metadataLocation(ctx)
+
+		if loc == nil {
+			return
+		}
+
+		labels := c.cloneCtx()
+
+		if loc.region != "" {
+			labels.setLabel(labelRegion, loc.region)
+		}
+
+		if loc.zone != "" {
+			labels.setLabel(labelZone, loc.zone)
+		}
+
+		c.ctx = labels
+	}
+}
+
+func metadataLocation(ctx context.Context) *location {
+	locationCache.mu.Lock()
+	defer locationCache.mu.Unlock()
+
+	if locationCache.loc == nil {
+		locationCache.loc = getLocation(ctx)
+	}
+
+	return locationCache.loc
+}
+
+func getLocation(ctx context.Context) *location {
+	// Both are formatted as "projects/NUMBER/zones/ZONE", or regions, and
+	// Cloud Run only has a region.
+	zone, zoneErr := getMetadata(ctx, "instance/zone")
+	region, regionErr := getMetadata(ctx, "instance/region")
+
+	if zoneErr != nil && regionErr != nil {
+		return nil
+	}
+
+	loc := &location{
+		region: lastPathElement(region),
+		zone:   lastPathElement(zone),
+	}
+
+	if loc.region == "" && loc.zone != "" {
+		if i := strings.LastIndexByte(loc.zone, '-'); i > 0 {
+			loc.region = loc.zone[:i]
+		}
+	}
+
+	return loc
+}
+
+func getMetadata(ctx context.Context, path string) (string, error) {
+	if atomic.LoadInt32(&metadataUnreachable) == 1 {
+		return "", errMetadataUnreachable
+	}
+
+	host := os.Getenv("GCE_METADATA_HOST")
+
+	if host == "" {
+		host = defaultMetadataHost
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+"/computeMetadata/v1/"+path, nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := metadataClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		if ctx.Err() == nil {
+			atomic.StoreInt32(&metadataUnreachable, 1)
+		}
+
+		return "", err
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", &metadataError{path: path, status: res.StatusCode}
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+var errMetadataUnreachable = errors.New("metadata: server unreachable")
+
+type metadataError struct {
+	path   string
+	status int
+}
+
+func (e *metadataError) Error() string {
+	return "metadata: " + e.path + ": " + http.StatusText(e.status)
+}
+
+func lastPathElement(s string) string {
+	return s[strings.LastIndexByte(s, '/')+1:]
+}
@@ -0,0 +1,144 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// resetMetadata forgets what was read from the metadata server.
+func resetMetadata() {
+	locationCache.mu.Lock()
+	locationCache.loc = nil
+	locationCache.mu.Unlock()
+	clusterCache.once = sync.Once{}
+	clusterCache.name = ""
+	projectCache.once = sync.Once{}
+	projectCache.id = ""
+	atomic.StoreInt32(&metadataUnreachable, 0)
+}
+
+func TestWithLocationLabelsFromMetadata(t *testing.T) {
+	var requests int32
+	metadata := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		value, ok := metadata[strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(value))
+	}))
+	defer server.Close()
+
+	host := os.Getenv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	defer os.Setenv("GCE_METADATA_HOST", host)
+
+	writer := bytes.NewBuffer(nil)
+	logWithContext := func(ctx context.Context) map[string]string {
+		defer writer.Reset()
+
+		zap.New(newTestCore(writer, WithLocationLabelsFromMetadata(ctx))).Info("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Labels
+	}
+
+	log := func() map[string]string {
+		return logWithContext(context.Background())
+	}
+
+	reset := func(values map[string]string) {
+		resetMetadata()
+		metadata = values
+		atomic.StoreInt32(&requests, 0)
+	}
+
+	t.Run("GCE", func(t *testing.T) {
+		reset(map[string]string{"instance/zone": "projects/123/zones/europe-west1-b"})
+
+		assert.Equal(t, map[string]string{"region": "europe-west1", "zone": "europe-west1-b"}, log())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Equal(t, map[string]string{"region": "europe-west1", "zone": "europe-west1-b"}, log())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Cloud Run", func(t *testing.T) {
+		reset(map[string]string{"instance/region": "projects/123/regions/us-central1"})
+
+		assert.Equal(t, map[string]string{"region": "us-central1"}, log())
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		reset(map[string]string{})
+
+		assert.Empty(t, log())
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		reset(map[string]string{"instance/zone": "projects/123/zones/europe-west1-b"})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.Empty(t, logWithContext(ctx))
+		assert.Equal(t, map[string]string{"region": "europe-west1", "zone": "europe-west1-b"}, log())
+
+		reset(map[string]string{})
+		assert.Empty(t, log())
+
+		metadata = map[string]string{"instance/zone": "projects/123/zones/europe-west1-b"}
+		assert.Equal(t, map[string]string{"region": "europe-west1", "zone": "europe-west1-b"}, log())
+	})
+}
+
+func TestWithLocationLabelsFromMetadata_Unreachable(t *testing.T) {
+	var requests int32
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	timeout := metadataClient.Timeout
+	metadataClient.Timeout = 10 * time.Millisecond
+	defer func() { metadataClient.Timeout = timeout }()
+	resetMetadata()
+	defer resetMetadata()
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		NewCore(zapcore.NewNopCore(), WithLocationLabelsFromMetadata(context.Background()))
+	}
+
+	DetectResource(context.Background())
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
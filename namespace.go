@@ -0,0 +1,119 @@
+package stackdriver
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// namespaceIndex returns the index of the first namespace of fields, or their
+// length if there is none.
+func namespaceIndex(fields []zapcore.Field) int {
+	for i, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			return i
+		}
+	}
+
+	return len(fields)
+}
+
+// namespacePrefix returns the path of the namespaces opened by the fields
+// given to With, such as "foo.bar.", which the keys of the fields of its
+// entries are under.
+func (c *Core) namespacePrefix() string {
+	if len(c.nested) == 0 {
+		return ""
+	}
+
+	b := strings.Builder{}
+
+	for _, f := range c.nested {
+		if f.Type == zapcore.NamespaceType {
+			b.WriteString(f.Key)
+			b.WriteByte('.')
+		}
+	}
+
+	return b.String()
+}
+
+// namespacedKey returns the key of the context field f is when its namespace
+// path is prefix, such as "context.user" for a user string in the context
+// namespace, or its own key otherwise.
+func namespacedKey(prefix string, f zapcore.Field) string {
+	if prefix == "" {
+		return f.Key
+	}
+
+	var ok bool
+
+	switch prefix + f.Key {
+	case logKeyContextHTTPRequest:
+		_, ok = f.Interface.(*HTTPRequest)
+	case logKeyContextReportLocation:
+		_, ok = f.Interface.(*ReportLocation)
+	case logKeyContextUser:
+		ok = f.Type == zapcore.StringType
+	}
+
+	if ok {
+		return prefix + f.Key
+	}
+
+	return f.Key
+}
+
+// nest orders the fields of an entry, whose first n are those of its payload
+// and the others its metadata, so that the metadata is left at the root of
+// the entry by the namespaces of the payload and of the fields given to
+// With. Fields added at the root by the Core, such as the serviceContext, are
+// moved out of the namespaces, and namespaces left empty are dropped.
+func (c *Core) nest(fields []zapcore.Field, n int) []zapcore.Field {
+	payload, metadata := fields[:n], fields[n:]
+	i := namespaceIndex(payload)
+
+	if len(c.nested) == 0 && i == len(payload) {
+		return fields
+	}
+
+	if len(c.nested) > 0 {
+		i = 0
+	}
+
+	nested := make([]zapcore.Field, 0, len(c.nested)+len(payload)-i)
+	nested = append(append(nested, c.nested...), payload[i:]...)
+
+	output := make([]zapcore.Field, 0, len(fields)+len(c.nested))
+	output = append(append(output, payload[:i]...), metadata...)
+
+	for _, f := range nested {
+		if f.Key == logKeyServiceContext && f.Type != zapcore.NamespaceType {
+			output = append(output, f)
+		}
+	}
+
+	// A namespace is only kept when a field other than a namespace follows.
+	keep := make([]bool, len(nested))
+	filled := false
+
+	for j := len(nested) - 1; j >= 0; j-- {
+		switch f := nested[j]; {
+		case f.Type == zapcore.NamespaceType:
+			keep[j] = filled
+		case f.Type == zapcore.SkipType:
+			keep[j] = true
+		case f.Key != logKeyServiceContext:
+			keep[j] = true
+			filled = true
+		}
+	}
+
+	for j, f := range nested {
+		if keep[j] {
+			output = append(output, f)
+		}
+	}
+
+	return output
+}
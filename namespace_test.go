@@ -0,0 +1,67 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCore_Namespaces(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer))
+
+	log := func(logger *zap.Logger, fields ...zap.Field) map[string]interface{} {
+		defer writer.Reset()
+
+		logger.Info("", fields...)
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, 1, strings.Count(writer.String(), `"context":`), writer.String())
+		return actual
+	}
+
+	t.Run("With", func(t *testing.T) {
+		child := logger.With(zap.Namespace("req"), zap.String("id", "1"), LogLabels(map[string]string{"a": "b"}))
+		actual := log(child, zap.String("foo", "bar"), LogUser("alice"), LogServiceContext(&ServiceContext{Service: "baz"}))
+
+		assert.Equal(t, map[string]interface{}{"id": "1", "foo": "bar"}, actual["req"])
+		assert.Equal(t, map[string]interface{}{"user": "alice"}, actual["context"])
+		assert.Equal(t, map[string]interface{}{"a": "b"}, actual[logKeyLabels])
+		assert.Equal(t, map[string]interface{}{"service": "baz", "version": ""}, actual[logKeyServiceContext])
+	})
+
+	t.Run("Entry", func(t *testing.T) {
+		actual := log(logger, zap.String("foo", "bar"), zap.Namespace("req"), zap.Int("n", 1), LogTrace("foo", testTraceID))
+
+		assert.Equal(t, "bar", actual["foo"])
+		assert.Equal(t, map[string]interface{}{"n": float64(1)}, actual["req"])
+		assert.Equal(t, "projects/foo/traces/"+testTraceID, actual[logKeyTrace])
+	})
+
+	t.Run("Context namespace", func(t *testing.T) {
+		actual := log(logger, zap.Namespace("context"), zap.String("user", "alice"))
+
+		assert.Equal(t, map[string]interface{}{"user": "alice"}, actual["context"])
+	})
+
+	t.Run("Empty namespace", func(t *testing.T) {
+		actual := log(logger.With(zap.Namespace("req")), LogUser("alice"))
+
+		assert.NotContains(t, actual, "req")
+		assert.Equal(t, map[string]interface{}{"user": "alice"}, actual["context"])
+	})
+}
+
+func TestAppendFieldsNamespaces(t *testing.T) {
+	core := newCore(bytes.NewBuffer(nil))
+	child := core.With([]zap.Field{zap.Namespace("req"), zap.String("id", "1")}).(*Core)
+
+	msg := child.appendFields("msg", []zap.Field{zap.Int("a", 1), zap.Namespace("db"), zap.Int("b", 2)})
+	assert.Equal(t, "msg req.a=1 req.db.b=2", msg)
+}
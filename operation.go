@@ -0,0 +1,144 @@
+package stackdriver
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Operation struct {
+	ID       string `json:"id"`
+	Producer string `json:"producer"`
+	First    bool   `json:"first,omitempty"`
+	Last     bool   `json:"last,omitempty"`
+}
+
+func (o *Operation) Clone() *Operation {
+	return &Operation{
+		ID:       o.ID,
+		Producer: o.Producer,
+		First:    o.First,
+		Last:     o.Last,
+	}
+}
+
+func (o *Operation) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("id", o.ID)
+	e.AddString("producer", o.Producer)
+
+	if o.First {
+		e.AddBool("first", o.First)
+	}
+
+	if o.Last {
+		e.AddBool("last", o.Last)
+	}
+
+	return nil
+}
+
+// LogOperation sets the operation of the entry, which Cloud Logging groups the
+// entries of. Given to With, it sets the operation of every entry of the child
+// logger, and entries can still set their own, such as to mark the last one.
+// StartOperation does this for an operation that starts and ends in the same
+// function.
+func LogOperation(id, producer string, first, last bool) zapcore.Field {
+	return zap.Object(logKeyOperation, &Operation{
+		ID:       id,
+		Producer: producer,
+		First:    first,
+		Last:     last,
+	})
+}
+
+// OperationLogger is a logger whose entries all belong to the same operation.
+type OperationLogger struct {
+	*zap.Logger
+
+	id       string
+	producer string
+	end      sync.Once
+}
+
+// StartOperation logs the first entry of an operation and returns a logger
+// which adds the operation to every entry. Call End, typically deferred, to
+// log the last entry of the operation.
+func StartOperation(logger *zap.Logger, id, producer string) *OperationLogger {
+	op := &OperationLogger{
+		Logger:   logger.With(LogOperation(id, producer, false, false)),
+		id:       id,
+		producer: producer,
+	}
+
+	op.WithOptions(zap.AddCallerSkip(1)).Info("operation started", LogOperation(id, producer, true, false))
+	return op
+}
+
+// End logs the last entry of the operation with the given fields, whose caller
+// is that of End. Only the first call logs anything.
+func (o *OperationLogger) End(fields ...zapcore.Field) {
+	first := false
+	o.end.Do(func() { first = true })
+
+	if !first {
+		return
+	}
+
+	fields = append(fields[:len(fields):len(fields)], LogOperation(o.id, o.producer, false, true))
+	o.WithOptions(zap.AddCallerSkip(1)).Info("operation ended", fields...)
+}
+
+// workflowProducer is the producer of the operations of LogWorkflowStep.
+const workflowProducer = "workflow"
+
+// workflowBoundaries are the statuses of LogWorkflowStep which start or end a
+// workflow. Other statuses, such as "running" or "succeeded", are those of
+// the steps in between.
+var workflowBoundaries = map[string]struct{ first, last bool }{
+	"started":     {first: true},
+	"completed":   {last: true},
+	"failed":      {last: true},
+	"compensated": {last: true},
+}
+
+type workflowStep struct {
+	workflowID string
+	step       string
+	status     string
+	duration   time.Duration
+}
+
+func (w *workflowStep) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("id", w.workflowID)
+	e.AddString("step", w.step)
+	e.AddString("status", w.status)
+	e.AddDuration("duration", w.duration)
+	return nil
+}
+
+func (w *workflowStep) operation() *Operation {
+	boundary := workflowBoundaries[w.status]
+
+	return &Operation{
+		ID:       w.workflowID,
+		Producer: workflowProducer,
+		First:    boundary.first,
+		Last:     boundary.last,
+	}
+}
+
+// LogWorkflowStep logs that step of a workflow, such as a saga, took d and
+// ended with status. The entries of a workflow are grouped as an operation
+// whose ID is workflowID. The "started" status marks the first entry of the
+// operation and the "completed", "failed" and "compensated" statuses mark
+// the last one.
+func LogWorkflowStep(workflowID, step, status string, d time.Duration) zapcore.Field {
+	return zap.Object("workflow", &workflowStep{
+		workflowID: workflowID,
+		step:       step,
+		status:     status,
+		duration:   d,
+	})
+}
@@ -0,0 +1,156 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOperation_Clone(t *testing.T) {
+	src := &Operation{
+		ID:       "foo",
+		Producer: "bar",
+		First:    true,
+		Last:     true,
+	}
+
+	res := src.Clone()
+	assert.Equal(t, src, res)
+}
+
+func TestOperation_MarshalLogObject(t *testing.T) {
+	t.Run("First", func(t *testing.T) {
+		enc := new(ObjectEncoder)
+		op := &Operation{
+			ID:       "foo",
+			Producer: "bar",
+			First:    true,
+		}
+
+		enc.On("AddString", "id", op.ID).Once()
+		enc.On("AddString", "producer", op.Producer).Once()
+		enc.On("AddBool", "first", true).Once()
+		require.Nil(t, op.MarshalLogObject(enc))
+		enc.AssertExpectations(t)
+	})
+
+	t.Run("Last", func(t *testing.T) {
+		enc := new(ObjectEncoder)
+		op := &Operation{
+			ID:       "foo",
+			Producer: "bar",
+			Last:     true,
+		}
+
+		enc.On("AddString", "id", op.ID).Once()
+		enc.On("AddString", "producer", op.Producer).Once()
+		enc.On("AddBool", "last", true).Once()
+		require.Nil(t, op.MarshalLogObject(enc))
+		enc.AssertExpectations(t)
+	})
+}
+
+func TestStartOperation(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	op := StartOperation(logger, "foo", "bar")
+	op.Info("working")
+	op.End()
+	op.End()
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 3)
+
+	expected := []*Operation{
+		{ID: "foo", Producer: "bar", First: true},
+		{ID: "foo", Producer: "bar"},
+		{ID: "foo", Producer: "bar", Last: true},
+	}
+
+	for i, line := range lines {
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Equal(t, expected[i], actual.Operation)
+	}
+}
+
+func TestStartOperation_Caller(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	op := StartOperation(zap.New(newCore(writer), zap.AddCaller()), "foo", "bar")
+	fields := make([]zapcore.Field, 1, 2)
+	fields[0] = zap.String("foo", "bar")
+
+	op.End(fields...)
+	assert.Equal(t, zapcore.Field{}, fields[:2][1])
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 2)
+
+	for _, line := range lines {
+		var actual struct {
+			Caller string `json:"caller"`
+		}
+
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		assert.Contains(t, actual.Caller, "operation_test.go")
+	}
+}
+
+func TestCore_RawOperation(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	logger.Info("", zap.String(logKeyOperation, "x"))
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "x", actual[logKeyOperation])
+}
+
+func TestLogWorkflowStep(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	fields := []zapcore.Field{
+		LogWorkflowStep("order-42", "reserve", "started", 0),
+		LogWorkflowStep("order-42", "charge", "succeeded", 120*time.Millisecond),
+		LogWorkflowStep("order-42", "ship", "completed", 2*time.Second),
+	}
+
+	for _, field := range fields {
+		logger.Info("", field)
+	}
+
+	type workflowEntry struct {
+		logEntry
+
+		Workflow map[string]interface{} `json:"workflow"`
+	}
+
+	var entries []workflowEntry
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual workflowEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		entries = append(entries, actual)
+	}
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, &Operation{ID: "order-42", Producer: "workflow", First: true}, entries[0].Operation)
+	assert.Equal(t, &Operation{ID: "order-42", Producer: "workflow"}, entries[1].Operation)
+	assert.Equal(t, &Operation{ID: "order-42", Producer: "workflow", Last: true}, entries[2].Operation)
+	assert.Equal(t, map[string]interface{}{
+		"id":       "order-42",
+		"step":     "charge",
+		"status":   "succeeded",
+		"duration": float64(120),
+	}, entries[1].Workflow)
+}
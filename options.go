@@ -0,0 +1,330 @@
+package stackdriver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	labelChannel            = "channel"
+	labelShard              = "shard"
+	labelDataClassification = "dataClassification"
+	labelSamplingPriority   = "samplingPriority"
+)
+
+// WithReportLocation sets SetReportLocation.
+func WithReportLocation(enabled bool) Option {
+	return func(c *Core) {
+		c.SetReportLocation = enabled
+	}
+}
+
+// WithMessageFields sets MessageFields.
+func WithMessageFields(enabled bool) Option {
+	return func(c *Core) {
+		c.MessageFields = enabled
+	}
+}
+
+// WithSourceLocation sets SetSourceLocation.
+func WithSourceLocation(enabled bool) Option {
+	return func(c *Core) {
+		c.SetSourceLocation = enabled
+	}
+}
+
+// WithLevelSeverity sets LevelSeverity to a copy of severities.
+func WithLevelSeverity(severities map[zapcore.Level]string) Option {
+	m := make(map[zapcore.Level]string, len(severities))
+
+	for lv, s := range severities {
+		m[lv] = s
+	}
+
+	return func(c *Core) {
+		c.LevelSeverity = m
+	}
+}
+
+// SeverityMapper returns the severity of the entries logged at lv, or an empty
+// string for them to keep theirs. Its severities must be known to Cloud
+// Logging, others are written as DEFAULT.
+type SeverityMapper func(lv zapcore.Level) string
+
+// WithSeverityMapper sets the severity of entries by the level they are logged
+// at with mapper, such as NearestSeverity for custom levels. It takes
+// precedence over LevelSeverity, which still applies to the levels mapper
+// returns an empty string for. Unlike LevelSeverity, mapper can tell apart
+// any number of levels without listing them.
+func WithSeverityMapper(mapper SeverityMapper) Option {
+	return func(c *Core) {
+		c.severityMap = mapper
+	}
+}
+
+// WithLabels adds labels to every entry.
+func WithLabels(labels map[string]string) Option {
+	return func(c *Core) {
+		ctx := c.cloneCtx()
+
+		for k, v := range labels {
+			ctx.setLabel(k, v)
+		}
+
+		c.ctx = ctx
+	}
+}
+
+// WithCostLabels adds labels attributing the cost of the logs, such as a team
+// or a cost center, to every entry.
+func WithCostLabels(labels map[string]string) Option {
+	return WithLabels(labels)
+}
+
+// WithServiceContext adds the service context with the given name and version
+// to every entry, for Error Reporting.
+func WithServiceContext(name, version string) Option {
+	return func(c *Core) {
+		c.serviceContext = &ServiceContext{
+			Service: name,
+			Version: version,
+		}
+	}
+}
+
+// WithProjectID sets the project the bare trace IDs logged with LogTraceID
+// belong to.
+func WithProjectID(projectID string) Option {
+	return func(c *Core) {
+		c.projectID = projectID
+	}
+}
+
+// WithChannel adds the channel the binary was released to, such as "canary"
+// or "stable", to every entry as the "channel" label.
+func WithChannel(channel string) Option {
+	return withLabel(labelChannel, channel)
+}
+
+// WithShard adds the shard or partition a logger works on to every entry as
+// the "shard" label. Child loggers of a different shard can use LogLabels to
+// replace it.
+func WithShard(id string) Option {
+	return withLabel(labelShard, id)
+}
+
+// WithDataClassification adds the classification of the data a logger may
+// log, such as "pii" or "public", to every entry as the "dataClassification"
+// label, so that sinks can route the entries accordingly.
+func WithDataClassification(class string) Option {
+	return withLabel(labelDataClassification, class)
+}
+
+func withLabel(key, value string) Option {
+	return func(c *Core) {
+		ctx := c.cloneCtx()
+		ctx.setLabel(key, value)
+		c.ctx = ctx
+	}
+}
+
+// WithDPanicLevel writes entries logged at DPanicLevel, which are CRITICAL by
+// default, with the severity of lv instead. This doesn't change whether a
+// development logger panics.
+func WithDPanicLevel(lv zapcore.Level) Option {
+	return func(c *Core) {
+		c.remapDPanic = true
+		c.dpanicLevel = lv
+	}
+}
+
+var readBuildInfo = debug.ReadBuildInfo
+
+// WithVersionFromBuildInfo sets the version of the service contexts logged
+// without one to the VCS revision the binary was built from, so that Error
+// Reporting groups errors by release. The revision ends with "-dirty" if the
+// working tree had local changes. The module version is used when the binary
+// wasn't built from a VCS checkout.
+func WithVersionFromBuildInfo() Option {
+	return func(c *Core) {
+		c.version = buildInfoVersion()
+	}
+}
+
+func buildInfoVersion() string {
+	info, ok := readBuildInfo()
+
+	if !ok {
+		return ""
+	}
+
+	var revision string
+	var modified bool
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+
+	if revision == "" {
+		if info.Main.Version == "(devel)" {
+			return ""
+		}
+
+		return info.Main.Version
+	}
+
+	if modified {
+		revision += "-dirty"
+	}
+
+	return revision
+}
+
+const labelStackHash = "stackHash"
+
+// WithStacktraceHash labels entries which have a stacktrace with a hash of
+// the functions in its top frames, so that repeated errors can be grouped by
+// where they happen. Line numbers aren't hashed, for the hash to survive
+// unrelated changes to the code. All the frames are hashed if frames is 0 or
+// less.
+func WithStacktraceHash(frames int) Option {
+	return func(c *Core) {
+		c.stackHash = true
+		c.stackFrames = frames
+	}
+}
+
+// stackHash hashes the function names of the top frames of stack, formatted
+// like the stacktraces of zap entries: a line with the function name of each
+// frame followed by a line with its location, indented with a tab.
+func stackHash(stack string, frames int) string {
+	h := fnv.New64a()
+	n := 0
+
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		if frames > 0 && n == frames {
+			break
+		}
+
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+		n++
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// processStart is when the package was initialized, close enough to when the
+// process started.
+var processStart = time.Now()
+
+// WithUptime adds the time elapsed since the process started to every entry,
+// as the uptime field, to relate issues to recent restarts.
+func WithUptime() Option {
+	return func(c *Core) {
+		c.uptime = true
+	}
+}
+
+// WithSchemaVersion adds version to every entry, as the logSchemaVersion
+// field, for consumers to parse entries by the version of their shape.
+func WithSchemaVersion(version string) Option {
+	return func(c *Core) {
+		c.Core = c.Core.With([]zapcore.Field{zap.String("logSchemaVersion", version)})
+	}
+}
+
+// WithSampledDebug writes the DEBUG entries of loggers whose trace is sampled,
+// whatever the level of the Core, so that sampled requests are logged in full.
+// The trace must be set with With, since the level of an entry is checked
+// before its fields are known.
+func WithSampledDebug() Option {
+	return func(c *Core) {
+		c.sampledDebug = true
+	}
+}
+
+// WithSamplingPriorityLabel adds the "samplingPriority" label to the entries
+// with a trace, "1" if the trace is sampled and "0" otherwise.
+func WithSamplingPriorityLabel() Option {
+	return func(c *Core) {
+		c.samplingPrio = true
+	}
+}
+
+func samplingPriority(sampled bool) string {
+	if sampled {
+		return "1"
+	}
+
+	return "0"
+}
+
+// WithInsertID sets the insertId of every entry without one set by LogInsertID
+// to the value returned by generate, which must be safe for concurrent use.
+// Cloud Logging deduplicates the entries with the same insertId and
+// timestamp, and gives an insertId to those without one, which the logging
+// agent may only do once it has batched entries. UniqueInsertIDs keeps
+// entries logged in the same instant apart, and SequentialInsertIDs gives
+// reproducible output in tests.
+func WithInsertID(generate func() string) Option {
+	return func(c *Core) {
+		c.insertID = generate
+	}
+}
+
+// SequentialInsertIDs returns a generator of the insertIds prefix1, prefix2
+// and so on, for WithInsertID.
+func SequentialInsertIDs(prefix string) func() string {
+	var n uint64
+
+	return func() string {
+		return prefix + strconv.FormatUint(atomic.AddUint64(&n, 1), 10)
+	}
+}
+
+// UniqueInsertIDs returns a generator of insertIds unique across processes,
+// for WithInsertID. They are made of a random prefix drawn once followed by
+// a sequence number, so that they are cheap to generate.
+func UniqueInsertIDs() func() string {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+	}
+
+	return SequentialInsertIDs(hex.EncodeToString(b) + "-")
+}
+
+// WithErrorReporting makes the ERROR entries and above with a stack trace
+// reported to Error Reporting as errors of the service name at version. The
+// stack trace is appended to their message in the format of runtime.Stack,
+// since Error Reporting doesn't parse the one written by zap.
+func WithErrorReporting(name, version string) Option {
+	withServiceContext := WithServiceContext(name, version)
+
+	return func(c *Core) {
+		withServiceContext(c)
+		c.errReporting = true
+	}
+}
@@ -0,0 +1,501 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestCore(writer *bytes.Buffer, opts ...Option) *Core {
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+	return NewCore(zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.DebugLevel), opts...)
+}
+
+func TestWithSourceLocation(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+
+	t.Run("Source location only", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger := zap.New(newTestCore(writer, WithSourceLocation(true)), zap.AddCaller())
+		_, file, line, _ := runtime.Caller(0)
+		logger.Info("")
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		require.IsType(t, map[string]interface{}{}, actual[logKeySourceLocation])
+		loc := actual[logKeySourceLocation].(map[string]interface{})
+		assert.Equal(t, file, loc["file"])
+		assert.Equal(t, strconv.Itoa(line+1), loc["line"])
+		assert.Contains(t, loc["function"], "TestWithSourceLocation")
+		assert.NotContains(t, actual["context"], "reportLocation")
+	})
+
+	t.Run("Both locations", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger := zap.New(newTestCore(writer, WithSourceLocation(true), WithReportLocation(true)), zap.AddCaller())
+		logger.Error("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.NotNil(t, actual.Context.ReportLocation)
+		assert.Contains(t, writer.String(), logKeySourceLocation)
+	})
+
+	t.Run("No caller", func(t *testing.T) {
+		defer writer.Reset()
+
+		zap.New(newTestCore(writer, WithSourceLocation(true))).Info("")
+
+		assert.NotContains(t, writer.String(), logKeySourceLocation)
+	})
+
+	t.Run("Explicit report location", func(t *testing.T) {
+		defer writer.Reset()
+
+		zap.New(newTestCore(writer, WithSourceLocation(true))).Info("", LogReportLocation(&ReportLocation{
+			FilePath:     "foo.go",
+			LineNumber:   42,
+			FunctionName: "bar",
+		}))
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, map[string]interface{}{
+			"file":     "foo.go",
+			"line":     "42",
+			"function": "bar",
+		}, actual[logKeySourceLocation])
+	})
+}
+
+func TestWithLevelSeverity(t *testing.T) {
+	severities := map[zapcore.Level]string{zapcore.WarnLevel: "NOTICE"}
+	core := newTestCore(bytes.NewBuffer(nil), WithLevelSeverity(severities))
+	severities[zapcore.WarnLevel] = "ERROR"
+
+	assert.Equal(t, map[zapcore.Level]string{zapcore.WarnLevel: "NOTICE"}, core.LevelSeverity)
+}
+
+func TestWithSeverityMapper(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := newTestCore(writer, WithSeverityMapper(func(lv zapcore.Level) string {
+		if lv == zapcore.DPanicLevel {
+			return "EMERGENCY"
+		}
+
+		return NearestSeverity(lv)
+	}))
+	core.LevelSeverity = map[zapcore.Level]string{
+		zapcore.DPanicLevel: "ERROR",
+		zapcore.WarnLevel:   "NOTICE",
+	}
+	logger := zap.New(core)
+
+	log := func(lv zapcore.Level) string {
+		defer writer.Reset()
+
+		logger.Check(lv, "").Write()
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Severity
+	}
+
+	assert.Equal(t, "EMERGENCY", log(zapcore.DPanicLevel))
+	assert.Equal(t, "NOTICE", log(zapcore.WarnLevel))
+	assert.Equal(t, "INFO", log(zapcore.InfoLevel))
+	assert.Equal(t, "EMERGENCY", log(zapcore.Level(10)))
+}
+
+func TestNearestSeverity(t *testing.T) {
+	assert.Equal(t, "DEBUG", NearestSeverity(zapcore.Level(-2)))
+	assert.Equal(t, "EMERGENCY", NearestSeverity(zapcore.Level(10)))
+	assert.Equal(t, "", NearestSeverity(zapcore.WarnLevel))
+	assert.Equal(t, "", NearestSeverity(noticeLevel))
+}
+
+func TestWithLabels(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithLabels(map[string]string{
+		"team": "foo",
+		"env":  "prod",
+	})))
+
+	logger.With(LogLabels(map[string]string{"env": "staging", "job": "bar"})).Info("", LogLabels(map[string]string{"job": "baz"}))
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{
+		"team": "foo",
+		"env":  "staging",
+		"job":  "baz",
+	}, actual.Labels)
+}
+
+func TestWithCostLabels(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	costLabels := map[string]string{
+		"team":       "foo",
+		"costCenter": "bar",
+	}
+	logger := zap.New(newTestCore(writer, WithCostLabels(costLabels)))
+	costLabels["team"] = "baz"
+
+	logger.With(zap.String("foo", "bar")).With(LogUser("baz")).Warn("", LogContextError(context.Canceled))
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{
+		"team":       "foo",
+		"costCenter": "bar",
+		"canceled":   "true",
+	}, actual.Labels)
+}
+
+func TestWithChannel(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithChannel("canary")))
+
+	logger.With(zap.String("foo", "bar")).With(LogLabels(map[string]string{"team": "foo"})).Info("")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{
+		"channel": "canary",
+		"team":    "foo",
+	}, actual.Labels)
+}
+
+func TestWithShard(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithShard("7")))
+
+	logger.Info("")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"shard": "7"}, actual.Labels)
+
+	writer.Reset()
+	logger.With(LogLabels(map[string]string{"shard": "8"})).Info("")
+
+	actual = logEntry{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"shard": "8"}, actual.Labels)
+}
+
+func TestWithDataClassification(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithDataClassification("pii")))
+
+	logger.With(zap.String("foo", "bar")).With(LogUser("baz")).Info("")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, map[string]string{"dataClassification": "pii"}, actual.Labels)
+}
+
+func TestWithDPanicLevel(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+
+	zap.New(newTestCore(writer)).DPanic("")
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "CRITICAL", actual.Severity)
+
+	writer.Reset()
+	zap.New(newTestCore(writer, WithDPanicLevel(zapcore.ErrorLevel))).With(zap.String("foo", "bar")).DPanic("")
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "ERROR", actual.Severity)
+}
+
+func TestWithVersionFromBuildInfo(t *testing.T) {
+	defer func() {
+		readBuildInfo = debug.ReadBuildInfo
+	}()
+
+	writer := bytes.NewBuffer(nil)
+	log := func(ctx *ServiceContext) *ServiceContext {
+		defer writer.Reset()
+
+		zap.New(newTestCore(writer, WithVersionFromBuildInfo())).With(LogServiceContext(ctx)).Info("")
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.ServiceContext
+	}
+
+	t.Run("VCS revision", func(t *testing.T) {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Main: debug.Module{Version: "(devel)"},
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "abc123"},
+					{Key: "vcs.modified", Value: "false"},
+				},
+			}, true
+		}
+
+		src := &ServiceContext{Service: "foo"}
+		assert.Equal(t, &ServiceContext{Service: "foo", Version: "abc123"}, log(src))
+		assert.Empty(t, src.Version)
+		assert.Equal(t, &ServiceContext{Service: "foo", Version: "bar"}, log(&ServiceContext{Service: "foo", Version: "bar"}))
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "abc123"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			}, true
+		}
+
+		assert.Equal(t, &ServiceContext{Service: "foo", Version: "abc123-dirty"}, log(&ServiceContext{Service: "foo"}))
+	})
+
+	t.Run("Module version", func(t *testing.T) {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Main: debug.Module{Version: "v1.2.3"},
+			}, true
+		}
+
+		assert.Equal(t, &ServiceContext{Service: "foo", Version: "v1.2.3"}, log(&ServiceContext{Service: "foo"}))
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return nil, false
+		}
+
+		assert.Equal(t, &ServiceContext{Service: "foo"}, log(&ServiceContext{Service: "foo"}))
+	})
+
+	t.Run("WithServiceContext", func(t *testing.T) {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}},
+			}, true
+		}
+
+		for _, opts := range [][]Option{
+			{WithServiceContext("foo", ""), WithVersionFromBuildInfo()},
+			{WithVersionFromBuildInfo(), WithServiceContext("foo", "")},
+		} {
+			zap.New(newTestCore(writer, opts...)).Info("")
+
+			var actual logEntry
+			require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+			assert.Equal(t, &ServiceContext{Service: "foo", Version: "abc123"}, actual.ServiceContext)
+			writer.Reset()
+		}
+	})
+}
+
+func TestWithStacktraceHash(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithStacktraceHash(3)), zap.AddStacktrace(zapcore.ErrorLevel))
+	logError := func(msg string) {
+		logger.Error(msg)
+	}
+
+	logError("foo")
+	logError("bar")
+	logger.Error("baz")
+	logger.Warn("qux")
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	require.Len(t, lines, 4)
+
+	entries := make([]logEntry, len(lines))
+
+	for i, line := range lines {
+		require.Nil(t, json.Unmarshal([]byte(line), &entries[i]))
+	}
+
+	assert.Len(t, entries[0].Labels["stackHash"], 16)
+	assert.Equal(t, entries[0].Labels["stackHash"], entries[1].Labels["stackHash"])
+	assert.NotEqual(t, entries[0].Labels["stackHash"], entries[2].Labels["stackHash"])
+	assert.NotContains(t, entries[3].Labels, "stackHash")
+}
+
+func TestStackHash(t *testing.T) {
+	stack := "main.foo\n\t/src/main.go:10\nmain.bar\n\t/src/main.go:20\nmain.main\n\t/src/main.go:30"
+	moved := "main.foo\n\t/src/main.go:11\nmain.bar\n\t/src/main.go:21\nmain.run\n\t/src/main.go:31"
+
+	assert.Equal(t, stackHash(stack, 2), stackHash(moved, 2))
+	assert.NotEqual(t, stackHash(stack, 0), stackHash(moved, 0))
+}
+
+func TestWithUptime(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithUptime()))
+
+	log := func() float64 {
+		defer writer.Reset()
+
+		logger.Info("")
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		require.IsType(t, float64(0), actual["uptime"])
+		return actual["uptime"].(float64)
+	}
+
+	first := log()
+	time.Sleep(10 * time.Millisecond)
+	assert.Greater(t, log(), first)
+}
+
+func TestWithSchemaVersion(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithSchemaVersion("2")))
+
+	logger.With(zap.String("foo", "bar")).Info("")
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "2", actual["logSchemaVersion"])
+	assert.Equal(t, "bar", actual["foo"])
+}
+
+func TestWithSampledDebug(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+	core := NewCore(zapcore.NewCore(enc, zapcore.AddSync(writer), zapcore.InfoLevel), WithSampledDebug())
+	logger := zap.New(core)
+
+	logger.With(LogTrace("foo", testTraceID), LogTraceSampled(true)).Debug("sampled")
+	logger.With(LogTraceSpan("foo", testTraceID, "bar", true)).With(zap.String("foo", "bar")).Debug("sampled span")
+	logger.With(LogTrace("foo", testTraceID)).Debug("unsampled")
+	logger.With(LogTraceSampled(true)).Debug("untraced")
+	logger.Debug("plain")
+	logger.With(LogTrace("foo", testTraceID)).Info("info")
+
+	var messages []string
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		messages = append(messages, actual.Message)
+	}
+
+	assert.Equal(t, []string{"sampled", "sampled span", "info"}, messages)
+
+	writer.Reset()
+	zap.New(NewCore(core.Core)).With(LogTrace("foo", testTraceID), LogTraceSampled(true)).Debug("sampled")
+	assert.Empty(t, writer.String())
+}
+
+func TestWithSamplingPriorityLabel(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithSamplingPriorityLabel()))
+
+	labels := func(fields ...zapcore.Field) map[string]string {
+		defer writer.Reset()
+
+		logger.Info("", fields...)
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Labels
+	}
+
+	assert.Equal(t, map[string]string{"samplingPriority": "1"}, labels(LogTrace("foo", testTraceID), LogTraceSampled(true)))
+	assert.Equal(t, map[string]string{"samplingPriority": "0"}, labels(LogTrace("foo", testTraceID)))
+	assert.Empty(t, labels(LogTraceSampled(true)))
+
+	logger = zap.New(newTestCore(writer))
+	assert.Empty(t, labels(LogTrace("foo", testTraceID), LogTraceSampled(true)))
+}
+
+func TestWithInsertID(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithInsertID(SequentialInsertIDs("test-"))))
+
+	logger.Info("")
+	logger.With(zap.String("foo", "bar")).Warn("")
+
+	var ids []string
+
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		var actual struct {
+			InsertID string `json:"logging.googleapis.com/insertId"`
+		}
+
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		ids = append(ids, actual.InsertID)
+	}
+
+	assert.Equal(t, []string{"test-1", "test-2"}, ids)
+
+	writer.Reset()
+	zap.New(newTestCore(writer, WithInsertID(func() string { return "fixed" }))).Info("")
+	assert.Contains(t, writer.String(), `"logging.googleapis.com/insertId":"fixed"`)
+
+	writer.Reset()
+	zap.New(newTestCore(writer)).Info("")
+	assert.NotContains(t, writer.String(), "insertId")
+
+	writer.Reset()
+	logger.Info("", LogInsertID("explicit"))
+	assert.Contains(t, writer.String(), `"logging.googleapis.com/insertId":"explicit"`)
+}
+
+func TestUniqueInsertIDs(t *testing.T) {
+	foo, bar := UniqueInsertIDs(), UniqueInsertIDs()
+	ids := map[string]bool{}
+
+	for i := 0; i < 10; i++ {
+		ids[foo()] = true
+		ids[bar()] = true
+	}
+
+	assert.Len(t, ids, 20)
+	assert.Regexp(t, "^[0-9a-f]{16}-1$", UniqueInsertIDs()())
+}
+
+func TestWithErrorReporting(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithErrorReporting("foo", "1.2.3")), zap.AddStacktrace(zapcore.WarnLevel))
+
+	logger.Error("failed")
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, typeReportedErrorEvent, actual[logKeyType])
+	assert.Equal(t, map[string]interface{}{"service": "foo", "version": "1.2.3"}, actual[logKeyServiceContext])
+	assert.NotContains(t, actual, "stacktrace")
+
+	lines := strings.Split(actual["message"].(string), "\n")
+	require.True(t, len(lines) > 4)
+	assert.Equal(t, []string{"failed", "", "goroutine 1 [running]:"}, lines[:3])
+	assert.Contains(t, lines[3], "TestWithErrorReporting(...)")
+	assert.True(t, strings.HasPrefix(lines[4], "\t"))
+	assert.Contains(t, lines[4], "options_test.go:")
+
+	writer.Reset()
+	logger.Warn("warned")
+
+	actual = map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "warned", actual["message"])
+	assert.Contains(t, actual, "stacktrace")
+}
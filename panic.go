@@ -0,0 +1,74 @@
+package stackdriver
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Go runs fn in a new goroutine. If fn panics, the panic is recovered and
+// logged as a CRITICAL entry whose message holds the stack trace in the
+// format Error Reporting expects, along with the location of the panic.
+func Go(logger *zap.Logger, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logPanic(logger, r)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func logPanic(logger *zap.Logger, r interface{}) {
+	entry := zapcore.Entry{
+		Level:   zapcore.DPanicLevel,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("panic: %v\n\n%s", r, debug.Stack()),
+	}
+
+	// Check the core directly so that a development logger doesn't panic again.
+	if ce := logger.Core().Check(entry, nil); ce != nil {
+		var fields []zapcore.Field
+
+		if loc := panicLocation(); loc != nil {
+			fields = append(fields, LogReportLocation(loc))
+		}
+
+		ce.Write(fields...)
+	}
+}
+
+// panicLocation returns the location of the function which panicked, which is
+// the first frame after runtime.gopanic.
+func panicLocation() *ReportLocation {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	panicking := false
+
+	for {
+		frame, more := frames.Next()
+
+		if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return &ReportLocation{
+				FilePath:     frame.File,
+				LineNumber:   frame.Line,
+				FunctionName: frame.Function,
+			}
+		}
+
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type notifyWriter struct {
+	bytes.Buffer
+	written chan struct{}
+}
+
+func (w *notifyWriter) Write(p []byte) (int, error) {
+	n, err := w.Buffer.Write(p)
+	close(w.written)
+	return n, err
+}
+
+func TestGo(t *testing.T) {
+	writer := &notifyWriter{written: make(chan struct{})}
+	logger := zap.New(newCore(writer), zap.Development())
+
+	var line int
+
+	Go(logger, func() {
+		_, _, line, _ = runtime.Caller(0)
+		panic("boom")
+	})
+
+	select {
+	case <-writer.written:
+	case <-time.After(time.Second):
+		t.Fatal("panic was not logged")
+	}
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, "CRITICAL", actual.Severity)
+	assert.True(t, strings.HasPrefix(actual.Message, "panic: boom\n\ngoroutine "))
+	assert.Contains(t, actual.Message, "TestGo")
+
+	loc := actual.Context.ReportLocation
+	require.NotNil(t, loc)
+	assert.Equal(t, line+1, loc.LineNumber)
+	assert.True(t, strings.HasPrefix(loc.FunctionName, "github.com/pablote/zap-stackdriver.TestGo"))
+}
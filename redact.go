@@ -0,0 +1,152 @@
+package stackdriver
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redactor returns the value to write for the field, query parameter or
+// header key whose value is value, and false to drop it instead.
+type Redactor func(key, value string) (string, bool)
+
+// WithRedaction applies redact to the fields of every entry and of With, to
+// the query parameters, headers and referrer of the httpRequest, and to
+// the user of the context, whose key is "context.user", so that personal
+// data can be masked or dropped in a single place. The values of fields are
+// formatted as they are in messages, and fields are only replaced by a
+// string when redact changes their value. Objects and arrays are left as
+// they are.
+func WithRedaction(redact Redactor) Option {
+	return func(c *Core) {
+		c.redactor = redact
+	}
+}
+
+// RedactKeys is a Redactor replacing the values of the given keys, matched
+// regardless of case, with "[REDACTED]".
+func RedactKeys(keys ...string) Redactor {
+	redacted := make(map[string]bool, len(keys))
+
+	for _, k := range keys {
+		redacted[strings.ToLower(k)] = true
+	}
+
+	return func(key, value string) (string, bool) {
+		if redacted[strings.ToLower(key)] {
+			return redactedValue, true
+		}
+
+		return value, true
+	}
+}
+
+func (c *Core) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if c.redactor == nil {
+		return fields
+	}
+
+	output := fields[:0]
+
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.NamespaceType, zapcore.SkipType:
+			output = append(output, f)
+			continue
+		}
+
+		value := c.fieldValueToString(f)
+		redacted, ok := c.redactor(f.Key, value)
+
+		if !ok {
+			continue
+		}
+
+		if redacted != value {
+			f = zap.String(f.Key, redacted)
+		}
+
+		output = append(output, f)
+	}
+
+	return output
+}
+
+func (c *Core) redactContext(ctx *Context) {
+	if c.redactor == nil {
+		return
+	}
+
+	if ctx.User != "" {
+		if user, ok := c.redactor(logKeyContextUser, ctx.User); ok {
+			ctx.User = user
+		} else {
+			ctx.User = ""
+		}
+	}
+
+	if ctx.HTTPRequest == nil {
+		return
+	}
+
+	// The request may be shared with other entries.
+	req := ctx.HTTPRequest.Clone()
+	req.URL = c.redactURL(req.URL)
+	req.Referrer = c.redactURL(req.Referrer)
+
+	for name, value := range req.Headers {
+		if v, ok := c.redactor(name, value); ok {
+			req.Headers[name] = v
+		} else {
+			delete(req.Headers, name)
+		}
+	}
+
+	ctx.HTTPRequest = req
+}
+
+// redactURL applies the redactor to the query parameters of rawURL. URLs
+// without a query, or which can't be parsed, are left as they are.
+func (c *Core) redactURL(rawURL string) string {
+	if !strings.Contains(rawURL, "?") {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	changed := false
+
+	for name, values := range query {
+		kept := values[:0]
+
+		for _, v := range values {
+			redacted, ok := c.redactor(name, v)
+
+			if ok {
+				kept = append(kept, redacted)
+			}
+
+			changed = changed || !ok || redacted != v
+		}
+
+		if len(kept) == 0 {
+			delete(query, name)
+		} else {
+			query[name] = kept
+		}
+	}
+
+	if !changed {
+		return rawURL
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
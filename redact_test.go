@@ -0,0 +1,71 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWithRedaction(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	core := newTestCore(writer, WithRedaction(func(key, value string) (string, bool) {
+		switch key {
+		case "email", "context.user":
+			return value[:1] + "***", true
+		case "token", "Authorization":
+			return "", false
+		case "n":
+			return "0", true
+		}
+
+		return value, true
+	}))
+	core.MessageFields = true
+
+	req := &HTTPRequest{
+		URL:      "https://example.com/foo?token=abc&email=alice@example.com&page=2",
+		Referrer: "https://example.com/bar",
+		Headers:  map[string]string{"Authorization": "Bearer abc", "Accept": "*/*"},
+	}
+
+	logger := zap.New(core).With(zap.String("token", "abc"), zap.String("email", "bob@example.com"))
+	logger.Info("foo", LogUser("alice"), LogHTTPRequest(req), zap.String("email", "alice@example.com"), zap.Int("n", 5), zap.Int("m", 5))
+
+	var actual map[string]interface{}
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+
+	assert.Equal(t, "foo email=a*** n=0 m=5", actual["message"])
+	assert.Equal(t, "a***", actual["email"])
+	assert.Equal(t, "0", actual["n"])
+	assert.Equal(t, float64(5), actual["m"])
+	assert.NotContains(t, actual, "token")
+	assert.Equal(t, 1, strings.Count(writer.String(), `"email":"b***"`))
+
+	ctx := actual["context"].(map[string]interface{})
+	assert.Equal(t, "a***", ctx["user"])
+
+	httpRequest := ctx["httpRequest"].(map[string]interface{})
+	assert.Equal(t, "https://example.com/foo?email=a%2A%2A%2A&page=2", httpRequest["url"])
+	assert.Equal(t, "https://example.com/bar", httpRequest["referrer"])
+	assert.Equal(t, map[string]interface{}{"Accept": "*/*"}, httpRequest["headers"])
+
+	// The request given to the field is left as it is.
+	assert.Equal(t, "Bearer abc", req.Headers["Authorization"])
+}
+
+func TestRedactKeys(t *testing.T) {
+	redact := RedactKeys("Password", "token")
+
+	value, ok := redact("password", "foo")
+	assert.Equal(t, "[REDACTED]", value)
+	assert.True(t, ok)
+
+	value, ok = redact("user", "bar")
+	assert.Equal(t, "bar", value)
+	assert.True(t, ok)
+}
@@ -0,0 +1,156 @@
+package stackdriver
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+const (
+	labelCluster   = "cluster"
+	labelNamespace = "namespace"
+	labelPod       = "pod"
+)
+
+// Resource describes the GCP environment the workload runs in, as detected by
+// DetectResource.
+type Resource struct {
+	// ProjectID is the project the workload runs in.
+	ProjectID string
+
+	// Service and Version are the service and version of the serviceContext
+	// on Cloud Run, Cloud Functions and App Engine.
+	Service string
+	Version string
+
+	// Labels are the labels added to every entry, such as the zone, and the
+	// pod and cluster on GKE.
+	Labels map[string]string
+}
+
+// projectIDVariables are read in order for the project ID, before asking the
+// metadata server.
+var projectIDVariables = []string{"GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "GCLOUD_PROJECT"}
+
+// serviceVariables are the pairs of variables the service and its version
+// are read from, in order. Cloud Run services and Cloud Functions set
+// K_SERVICE, Cloud Run jobs CLOUD_RUN_JOB and App Engine GAE_SERVICE.
+var serviceVariables = [][2]string{
+	{"K_SERVICE", "K_REVISION"},
+	{"CLOUD_RUN_JOB", "CLOUD_RUN_EXECUTION"},
+	{"GAE_SERVICE", "GAE_VERSION"},
+}
+
+var clusterCache struct {
+	once sync.Once
+	name string
+}
+
+var projectCache struct {
+	once sync.Once
+	id   string
+}
+
+// DetectResource returns the environment the workload runs in, read from the
+// variables set by Cloud Run, Cloud Functions, App Engine and GKE and from
+// the metadata server. On GKE, the pod and its namespace are read from the
+// POD_NAME and POD_NAMESPACE variables, which are set from the Downward API,
+// the pod name defaulting to the host name. The metadata server is asked
+// like WithLocationLabelsFromMetadata does, once per process and for at most
+// 2 seconds.
+func DetectResource(ctx context.Context) *Resource {
+	r := &Resource{
+		ProjectID: firstEnv(projectIDVariables...),
+		Labels:    map[string]string{},
+	}
+
+	for _, names := range serviceVariables {
+		if service := os.Getenv(names[0]); service != "" {
+			r.Service = service
+			r.Version = os.Getenv(names[1])
+			break
+		}
+	}
+
+	if r.ProjectID == "" {
+		r.ProjectID = metadataProject(ctx)
+	}
+
+	if loc := metadataLocation(ctx); loc != nil {
+		if loc.region != "" {
+			r.Labels[labelRegion] = loc.region
+		}
+
+		if loc.zone != "" {
+			r.Labels[labelZone] = loc.zone
+		}
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		if cluster := metadataCluster(ctx); cluster != "" {
+			r.Labels[labelCluster] = cluster
+		}
+
+		if pod := firstEnv("POD_NAME", "HOSTNAME"); pod != "" {
+			r.Labels[labelPod] = pod
+		}
+
+		if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+			r.Labels[labelNamespace] = namespace
+		}
+	}
+
+	return r
+}
+
+// metadataProject returns the ID of the project, which the metadata server is
+// only asked once per process.
+func metadataProject(ctx context.Context) string {
+	projectCache.once.Do(func() {
+		projectCache.id, _ = getMetadata(ctx, "project/project-id")
+	})
+
+	return projectCache.id
+}
+
+// metadataCluster returns the name of the GKE cluster, which the metadata
+// server is only asked once per process.
+func metadataCluster(ctx context.Context) string {
+	clusterCache.once.Do(func() {
+		clusterCache.name, _ = getMetadata(ctx, "instance/attributes/cluster-name")
+	})
+
+	return clusterCache.name
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// WithAutoDetect configures the Core for the environment returned by
+// DetectResource: the serviceContext is set to its service and version, bare
+// trace IDs are formatted with its project like WithProjectID does, and its
+// labels are added to every entry.
+func WithAutoDetect(ctx context.Context) Option {
+	return func(c *Core) {
+		r := DetectResource(ctx)
+
+		if r.Service != "" {
+			WithServiceContext(r.Service, r.Version)(c)
+		}
+
+		if r.ProjectID != "" {
+			WithProjectID(r.ProjectID)(c)
+		}
+
+		if len(r.Labels) > 0 {
+			WithLabels(r.Labels)(c)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func serveMetadata(t *testing.T, metadata map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := metadata[strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(value))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	resetMetadata()
+	t.Cleanup(resetMetadata)
+}
+
+func clearResourceVariables(t *testing.T) {
+	names := append([]string{"KUBERNETES_SERVICE_HOST", "POD_NAME", "POD_NAMESPACE", "HOSTNAME"}, projectIDVariables...)
+
+	for _, pair := range serviceVariables {
+		names = append(names, pair[0], pair[1])
+	}
+
+	for _, name := range names {
+		t.Setenv(name, "")
+	}
+}
+
+func TestDetectResource(t *testing.T) {
+	t.Run("Cloud Run", func(t *testing.T) {
+		clearResourceVariables(t)
+		serveMetadata(t, map[string]string{
+			"project/project-id": "foo",
+			"instance/region":    "projects/123/regions/us-central1",
+		})
+		t.Setenv("K_SERVICE", "bar")
+		t.Setenv("K_REVISION", "bar-00001-abc")
+
+		assert.Equal(t, &Resource{
+			ProjectID: "foo",
+			Service:   "bar",
+			Version:   "bar-00001-abc",
+			Labels:    map[string]string{"region": "us-central1"},
+		}, DetectResource(context.Background()))
+	})
+
+	t.Run("GKE", func(t *testing.T) {
+		clearResourceVariables(t)
+		serveMetadata(t, map[string]string{
+			"project/project-id":               "foo",
+			"instance/zone":                    "projects/123/zones/europe-west1-b",
+			"instance/attributes/cluster-name": "baz",
+		})
+		t.Setenv("GOOGLE_CLOUD_PROJECT", "qux")
+		t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+		t.Setenv("HOSTNAME", "bar-7d4b9c-xkq2p")
+		t.Setenv("POD_NAMESPACE", "default")
+
+		assert.Equal(t, &Resource{
+			ProjectID: "qux",
+			Labels: map[string]string{
+				"region":    "europe-west1",
+				"zone":      "europe-west1-b",
+				"cluster":   "baz",
+				"pod":       "bar-7d4b9c-xkq2p",
+				"namespace": "default",
+			},
+		}, DetectResource(context.Background()))
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		clearResourceVariables(t)
+		serveMetadata(t, map[string]string{})
+
+		assert.Equal(t, &Resource{Labels: map[string]string{}}, DetectResource(context.Background()))
+	})
+}
+
+func TestWithAutoDetect(t *testing.T) {
+	clearResourceVariables(t)
+	serveMetadata(t, map[string]string{
+		"project/project-id": "foo",
+		"instance/region":    "projects/123/regions/us-central1",
+	})
+	t.Setenv("K_SERVICE", "bar")
+	t.Setenv("K_REVISION", "bar-00001-abc")
+
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithAutoDetect(context.Background())))
+	logger.Info("", LogTraceID(strings.ToUpper(testTraceID)))
+
+	var actual logEntry
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, &ServiceContext{Service: "bar", Version: "bar-00001-abc"}, actual.ServiceContext)
+	assert.Equal(t, map[string]string{"region": "us-central1"}, actual.Labels)
+	assert.Equal(t, "projects/foo/traces/"+testTraceID, actual.Trace)
+}
+
+func TestLogTraceID(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+
+	log := func(logger *zap.Logger) string {
+		defer writer.Reset()
+
+		logger.Info("", LogTraceID(testTraceID))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		return actual.Trace
+	}
+
+	assert.Equal(t, "projects/foo/traces/"+testTraceID, log(zap.New(newTestCore(writer, WithProjectID("foo")))))
+	assert.Equal(t, testTraceID, log(zap.New(newTestCore(writer))))
+	assert.Equal(t, zap.Skip(), LogTraceID("foo"))
+}
@@ -0,0 +1,71 @@
+package stackdriver
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type runtimeStats struct {
+	goroutines int
+	mem        runtime.MemStats
+}
+
+func (r *runtimeStats) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddInt("goroutines", r.goroutines)
+	e.AddUint64("heapAlloc", r.mem.HeapAlloc)
+	e.AddUint64("heapInuse", r.mem.HeapInuse)
+	e.AddUint64("heapObjects", r.mem.HeapObjects)
+	e.AddUint64("sys", r.mem.Sys)
+	e.AddUint32("numGC", r.mem.NumGC)
+	e.AddDuration("pauseTotal", time.Duration(r.mem.PauseTotalNs))
+	return nil
+}
+
+// defaultRuntimeStatsInterval is the interval of StartRuntimeStatsLogger when
+// the given one isn't positive.
+const defaultRuntimeStatsInterval = time.Minute
+
+// StartRuntimeStatsLogger logs the memory and GC statistics of the process
+// under the "runtime" key every interval, or every minute if it isn't
+// positive, until stop is called. stop waits for the background goroutine to
+// exit and is safe to call more than once.
+func StartRuntimeStatsLogger(logger *zap.Logger, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultRuntimeStatsInterval
+	}
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	once := sync.Once{}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				stats := &runtimeStats{goroutines: runtime.NumGoroutine()}
+				runtime.ReadMemStats(&stats.mem)
+				logger.Info("runtime stats", zap.Object("runtime", stats))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
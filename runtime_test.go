@@ -0,0 +1,49 @@
+package stackdriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStartRuntimeStatsLogger(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	stop := StartRuntimeStatsLogger(zap.New(obs), time.Millisecond)
+
+	// Polling by hand, since Eventually can panic after returning in this
+	// version of testify.
+	for deadline := time.Now().Add(time.Second); logs.Len() == 0; {
+		require.True(t, time.Now().Before(deadline), "no runtime stats logged")
+		time.Sleep(time.Millisecond)
+	}
+
+	stop()
+	stop()
+
+	count := logs.Len()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, count, logs.Len())
+
+	entry := logs.All()[0]
+	assert.Equal(t, "runtime stats", entry.Message)
+
+	stats := entry.ContextMap()["runtime"].(map[string]interface{})
+	assert.Contains(t, stats, "goroutines")
+	assert.Contains(t, stats, "heapAlloc")
+	assert.Contains(t, stats, "numGC")
+}
+
+func TestStartRuntimeStatsLogger_DefaultInterval(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		StartRuntimeStatsLogger(zap.New(obs), interval)()
+	}
+
+	assert.Equal(t, 0, logs.Len())
+}
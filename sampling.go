@@ -0,0 +1,188 @@
+package stackdriver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SeveritySamplingCore keeps 1 in N of the entries logged at a level, N being
+// the rate of that level. Entries below ErrorLevel whose level has no rate,
+// and entries at ErrorLevel and above whatever their rate, are always kept.
+// That includes the entries raised to ERROR by their fields, such as
+// LogHTTPError with a 5xx status, so the entries of sampled levels are
+// sampled by Write, once their fields are known.
+type SeveritySamplingCore struct {
+	zapcore.Core
+
+	// counters is shared with the cores returned by With so that the rates
+	// apply to the logger and its children together.
+	counters map[zapcore.Level]*samplingCounter
+}
+
+type samplingCounter struct {
+	rate  uint64
+	count uint64
+}
+
+// NewSeveritySamplingCore wraps core, such as a stackdriver Core, to sample
+// the entries logged to it by level. For example, this keeps 1 in 10 DEBUG
+// and INFO entries:
+//
+//	stackdriver.NewSeveritySamplingCore(core, map[zapcore.Level]int{
+//		zapcore.DebugLevel: 10,
+//		zapcore.InfoLevel:  10,
+//	})
+func NewSeveritySamplingCore(core zapcore.Core, rates map[zapcore.Level]int) *SeveritySamplingCore {
+	counters := make(map[zapcore.Level]*samplingCounter, len(rates))
+
+	for lv, rate := range rates {
+		if rate > 1 && lv < zapcore.ErrorLevel {
+			counters[lv] = &samplingCounter{rate: uint64(rate)}
+		}
+	}
+
+	return &SeveritySamplingCore{
+		Core:     core,
+		counters: counters,
+	}
+}
+
+// WithSeveritySampling makes a logger sample its entries by level, as
+// NewSeveritySamplingCore does.
+func WithSeveritySampling(rates map[zapcore.Level]int) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return NewSeveritySamplingCore(core, rates)
+	})
+}
+
+func (c *SeveritySamplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &SeveritySamplingCore{
+		Core:     c.Core.With(fields),
+		counters: c.counters,
+	}
+}
+
+func (c *SeveritySamplingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if _, ok := c.counters[entry.Level]; !ok {
+		return c.Core.Check(entry, ce)
+	}
+
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+// Write samples the entries of the levels with a rate, unless their fields
+// raise them to ERROR or above, and then checks the kept ones with the
+// wrapped core, so that its own decisions still apply.
+func (c *SeveritySamplingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if counter, ok := c.counters[entry.Level]; ok && !isErrorLevel(fieldsLevel(entry.Level, fields)) {
+		if (atomic.AddUint64(&counter.count, 1)-1)%counter.rate != 0 {
+			return nil
+		}
+	}
+
+	if ce := c.Core.Check(entry, nil); ce != nil {
+		ce.Write(fields...)
+	}
+
+	return nil
+}
+
+const (
+	samplingTick    = time.Second
+	samplingBuckets = 1024
+)
+
+// sampler counts the entries logged with the same level and message, like the
+// sampler of zap, to keep the first ones of every tick and then 1 in every
+// thereafter.
+type sampler struct {
+	initial    uint64
+	thereafter uint64
+	windows    [zapcore.ErrorLevel - zapcore.DebugLevel][samplingBuckets]samplingWindow
+}
+
+type samplingWindow struct {
+	resetAt int64
+	count   uint64
+}
+
+// WithSampling samples the entries logged below ErrorLevel with the same level
+// and message: the first initial of every second are kept, and then 1 in
+// every thereafter, or none if thereafter is 0. Unlike
+// zapcore.NewSamplerWithOptions, which would hide the context of the Core,
+// the sampling is done by the Core itself, so the kept entries are written
+// with the httpRequest, user and trace of their logger. Like zap, messages
+// are counted by their hash, so different messages may share a count.
+func WithSampling(initial, thereafter int) Option {
+	return func(c *Core) {
+		if initial < 0 {
+			initial = 0
+		}
+
+		if thereafter < 0 {
+			thereafter = 0
+		}
+
+		c.sampler = &sampler{
+			initial:    uint64(initial),
+			thereafter: uint64(thereafter),
+		}
+	}
+}
+
+// keep reports whether entry is kept, which it always is when s is nil.
+func (s *sampler) keep(entry zapcore.Entry) bool {
+	if s == nil || entry.Level < zapcore.DebugLevel || entry.Level >= zapcore.ErrorLevel {
+		return true
+	}
+
+	w := &s.windows[entry.Level-zapcore.DebugLevel][fnv32a(entry.Message)%samplingBuckets]
+	n := w.incr(entry.Time.UnixNano())
+
+	if n <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+func (w *samplingWindow) incr(now int64) uint64 {
+	resetAt := atomic.LoadInt64(&w.resetAt)
+
+	if resetAt > now {
+		return atomic.AddUint64(&w.count, 1)
+	}
+
+	atomic.StoreUint64(&w.count, 1)
+
+	if !atomic.CompareAndSwapInt64(&w.resetAt, resetAt, now+int64(samplingTick)) {
+		// Another entry started the tick first.
+		return atomic.AddUint64(&w.count, 1)
+	}
+
+	return 1
+}
+
+// fnv32a returns the FNV-1a hash of s without allocating.
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+
+	return h
+}
@@ -0,0 +1,109 @@
+package stackdriver
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSeveritySamplingCore(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(NewSeveritySamplingCore(newCore(writer), map[zapcore.Level]int{
+		zapcore.DebugLevel: 1,
+		zapcore.InfoLevel:  3,
+		zapcore.ErrorLevel: 10,
+	}))
+
+	count := func(log func(string, ...zapcore.Field), n int) int {
+		defer writer.Reset()
+
+		for i := 0; i < n; i++ {
+			log("")
+		}
+
+		return strings.Count(writer.String(), "\n")
+	}
+
+	assert.Equal(t, 3, count(logger.Info, 9))
+	assert.Equal(t, 3, count(logger.With(zap.String("foo", "bar")).Info, 7))
+	assert.Equal(t, 5, count(logger.Debug, 5))
+	assert.Equal(t, 5, count(logger.Warn, 5))
+	assert.Equal(t, 5, count(logger.Error, 5))
+}
+
+func TestSeveritySamplingCore_Leveler(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(NewSeveritySamplingCore(newCore(writer), map[zapcore.Level]int{
+		zapcore.InfoLevel: 10,
+	}))
+
+	for i := 0; i < 5; i++ {
+		logger.Info("", LogHTTPError(errors.New("boom"), 503))
+	}
+
+	assert.Equal(t, 5, strings.Count(writer.String(), `"severity":"ERROR"`))
+}
+
+func TestWithSeveritySampling(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer), WithSeveritySampling(map[zapcore.Level]int{
+		zapcore.InfoLevel: 2,
+	}))
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("third")
+
+	assert.Contains(t, writer.String(), "first")
+	assert.NotContains(t, writer.String(), "second")
+	assert.Contains(t, writer.String(), "third")
+}
+
+func TestWithSampling(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newTestCore(writer, WithSampling(2, 3)))
+
+	count := func(logger *zap.Logger, lv zapcore.Level, msg string, n int) int {
+		defer writer.Reset()
+
+		for i := 0; i < n; i++ {
+			if ce := logger.Check(lv, msg); ce != nil {
+				ce.Write()
+			}
+		}
+
+		return strings.Count(writer.String(), "\n")
+	}
+
+	// The first 2, then the 5th and the 8th.
+	assert.Equal(t, 4, count(logger, zapcore.InfoLevel, "foo", 8))
+	assert.Equal(t, 2, count(logger, zapcore.InfoLevel, "bar", 2))
+	assert.Equal(t, 2, count(logger, zapcore.DebugLevel, "foo", 3))
+	assert.Equal(t, 5, count(logger, zapcore.ErrorLevel, "foo", 5))
+
+	// The counts are shared with children, so the 11th is kept.
+	child := logger.With(LogUser("alice"))
+
+	for i := 0; i < 3; i++ {
+		child.Info("foo")
+	}
+
+	assert.Equal(t, 1, strings.Count(writer.String(), "\n"))
+	assert.Contains(t, writer.String(), `"context":{"user":"alice"}`)
+	writer.Reset()
+
+	assert.Equal(t, 2, count(zap.New(newTestCore(writer, WithSampling(2, 0))), zapcore.WarnLevel, "foo", 10))
+}
+
+func TestSamplingWindow(t *testing.T) {
+	w := &samplingWindow{}
+
+	assert.Equal(t, uint64(1), w.incr(0))
+	assert.Equal(t, uint64(2), w.incr(int64(samplingTick)-1))
+	assert.Equal(t, uint64(1), w.incr(int64(samplingTick)))
+}
@@ -0,0 +1,79 @@
+package stackdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// splitCore writes the entries at threshold and above to one core and the
+// others to another, by the level they are written at.
+type splitCore struct {
+	low       zapcore.Core
+	high      zapcore.Core
+	threshold zapcore.Level
+}
+
+// NewSplitCore returns a Core writing entries as JSON, with the levels enabled
+// by enab, to stdout below threshold and to stderr at threshold and above,
+// configured by opts. Entries go by the severity they are written with, so
+// NOTICE entries are below WarnLevel and ERROR ones are written to stderr
+// when WithLevelSeverity maps WarnLevel to ERROR.
+func NewSplitCore(stdout, stderr zapcore.WriteSyncer, enab zapcore.LevelEnabler, threshold zapcore.Level, opts ...Option) *Core {
+	enc := zapcore.NewJSONEncoder(EncoderConfig)
+
+	return NewCore(&splitCore{
+		low:       zapcore.NewCore(enc, stdout, enab),
+		high:      zapcore.NewCore(enc.Clone(), stderr, enab),
+		threshold: threshold,
+	}, opts...)
+}
+
+func (s *splitCore) Enabled(lv zapcore.Level) bool {
+	return s.low.Enabled(lv)
+}
+
+func (s *splitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &splitCore{
+		low:       s.low.With(fields),
+		high:      s.high.With(fields),
+		threshold: s.threshold,
+	}
+}
+
+func (s *splitCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s.Enabled(entry.Level) {
+		return ce.AddCore(entry, s)
+	}
+
+	return ce
+}
+
+func (s *splitCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if splitLevel(entry.Level) >= s.threshold {
+		return s.high.Write(entry, fields)
+	}
+
+	return s.low.Write(entry, fields)
+}
+
+func (s *splitCore) Sync() error {
+	err := s.low.Sync()
+
+	if highErr := s.high.Sync(); err == nil {
+		err = highErr
+	}
+
+	return err
+}
+
+// splitLevel returns the level of zap lv is ordered as, NOTICE being ordered
+// as InfoLevel and DEFAULT below DebugLevel.
+func splitLevel(lv zapcore.Level) zapcore.Level {
+	switch lv {
+	case noticeLevel:
+		return zapcore.InfoLevel
+	case defaultLevel:
+		return zapcore.DebugLevel - 1
+	}
+
+	return lv
+}
@@ -0,0 +1,67 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func splitSeverities(t *testing.T, buf *bytes.Buffer) []string {
+	var output []string
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal([]byte(line), &actual))
+		output = append(output, actual.Severity)
+	}
+
+	return output
+}
+
+func TestNewSplitCore(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	core := NewSplitCore(zapcore.AddSync(stdout), zapcore.AddSync(stderr), zapcore.InfoLevel, zapcore.ErrorLevel,
+		WithLevelSeverity(map[zapcore.Level]string{zapcore.DPanicLevel: "NOTICE", zapcore.WarnLevel: "FOO"}),
+	)
+	logger := zap.New(core).With(zap.String("foo", "bar"))
+
+	logger.Debug("dropped")
+	logger.Info("")
+	LogStartup(logger)
+	logger.Warn("")
+	logger.Error("")
+	logger.DPanic("")
+
+	assert.Equal(t, []string{"INFO", "NOTICE", "DEFAULT", "NOTICE"}, splitSeverities(t, stdout))
+	assert.Equal(t, []string{"ERROR"}, splitSeverities(t, stderr))
+	assert.Contains(t, stderr.String(), `"foo":"bar"`)
+}
+
+type syncErrorWriter struct {
+	bytes.Buffer
+
+	err error
+}
+
+func (w *syncErrorWriter) Sync() error {
+	return w.err
+}
+
+func TestNewSplitCore_Sync(t *testing.T) {
+	err := errors.New("foo")
+	core := NewSplitCore(&syncErrorWriter{}, &syncErrorWriter{err: err}, zapcore.InfoLevel, zapcore.ErrorLevel)
+
+	assert.Equal(t, err, core.Sync())
+}
@@ -0,0 +1,52 @@
+package stackdriver
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type sqlQuery struct {
+	query    string
+	duration time.Duration
+	slow     bool
+	loc      *ReportLocation
+}
+
+func (q *sqlQuery) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("query", q.query)
+	e.AddDuration("duration", q.duration)
+	e.AddBool("slow", q.slow)
+	return nil
+}
+
+func (q *sqlQuery) level(zapcore.Level) zapcore.Level {
+	if q.slow {
+		return zapcore.WarnLevel
+	}
+
+	return zapcore.InfoLevel
+}
+
+func (q *sqlQuery) reportLocation() *ReportLocation {
+	return q.loc
+}
+
+// LogSQL logs a query which took d to run. The entry is an INFO, unless the
+// query took longer than slowThreshold, which makes it a WARNING reported at
+// the caller's location. A slowThreshold of 0 or less never flags a query as
+// slow.
+func LogSQL(query string, d time.Duration, slowThreshold time.Duration) zapcore.Field {
+	q := &sqlQuery{
+		query:    query,
+		duration: d,
+		slow:     slowThreshold > 0 && d > slowThreshold,
+	}
+
+	if q.slow {
+		q.loc = callerLocation(1)
+	}
+
+	return zap.Object("sql", q)
+}
@@ -0,0 +1,66 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLogSQL(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	type sqlEntry struct {
+		logEntry
+
+		SQL map[string]interface{} `json:"sql"`
+	}
+
+	t.Run("Fast", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Debug("", LogSQL("SELECT 1", 5*time.Millisecond, 100*time.Millisecond))
+
+		var actual sqlEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "INFO", actual.Severity)
+		assert.Equal(t, map[string]interface{}{
+			"query":    "SELECT 1",
+			"duration": float64(5),
+			"slow":     false,
+		}, actual.SQL)
+		assert.Nil(t, actual.Context.ReportLocation)
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		defer writer.Reset()
+
+		_, file, line, _ := runtime.Caller(0)
+		logger.Debug("", LogSQL("SELECT 1", 250*time.Millisecond, 100*time.Millisecond))
+
+		var actual sqlEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "WARNING", actual.Severity)
+		assert.Equal(t, true, actual.SQL["slow"])
+		require.NotNil(t, actual.Context.ReportLocation)
+		assert.Equal(t, file, actual.Context.ReportLocation.FilePath)
+		assert.Equal(t, line+1, actual.Context.ReportLocation.LineNumber)
+	})
+
+	t.Run("No threshold", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Info("", LogSQL("SELECT 1", time.Hour, 0))
+
+		var actual sqlEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "INFO", actual.Severity)
+		assert.Equal(t, false, actual.SQL["slow"])
+	})
+}
@@ -0,0 +1,23 @@
+// Package stackdriverecho extracts the Stackdriver fields of requests handled
+// by Echo. It's a separate module so that Echo is only required by those who
+// use it.
+package stackdriverecho
+
+import (
+	"github.com/labstack/echo/v4"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap/zapcore"
+)
+
+// Fields returns the httpRequest context and the trace fields of the request
+// handled by c. The response status is included once it has been written.
+func Fields(c echo.Context, projectID string) []zapcore.Field {
+	req := stackdriver.NewHTTPRequest(c.Request())
+
+	if res := c.Response(); res.Committed {
+		req.ResponseStatusCode = res.Status
+	}
+
+	fields := []zapcore.Field{stackdriver.LogHTTPRequest(req)}
+	return append(fields, stackdriver.RequestTraceFields(c.Request(), projectID)...)
+}
@@ -0,0 +1,32 @@
+package stackdriverecho
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFields(t *testing.T) {
+	req := httptest.NewRequest("GET", "/foo", nil)
+	req.RemoteAddr = "1.2.3.4:5678"
+	req.Header.Set("X-Cloud-Trace-Context", "4bf92f3577b34da6a3ce929d0e0e4736/1;o=1")
+
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	assert.Nil(t, c.String(201, "ok"))
+
+	assert.Equal(t, []zapcore.Field{
+		stackdriver.LogHTTPRequest(&stackdriver.HTTPRequest{
+			Method:             "GET",
+			URL:                "http://example.com/foo",
+			ResponseStatusCode: 201,
+			RemoteIP:           "1.2.3.4",
+		}),
+		stackdriver.LogTrace("foo", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		stackdriver.LogSpanID("0000000000000001"),
+		stackdriver.LogTraceSampled(true),
+	}, Fields(c, "foo"))
+}
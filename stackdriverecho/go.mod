@@ -0,0 +1,12 @@
+module github.com/pablote/zap-stackdriver/stackdriverecho
+
+go 1.14
+
+replace github.com/pablote/zap-stackdriver => ../
+
+require (
+	github.com/labstack/echo/v4 v4.1.16
+	github.com/pablote/zap-stackdriver v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.4.0
+	go.uber.org/zap v1.15.0
+)
@@ -0,0 +1,23 @@
+// Package stackdrivergin extracts the Stackdriver fields of requests handled
+// by Gin. It's a separate module so that Gin is only required by those who
+// use it.
+package stackdrivergin
+
+import (
+	"github.com/gin-gonic/gin"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap/zapcore"
+)
+
+// Fields returns the httpRequest context and the trace fields of the request
+// handled by c. The response status is included once it has been written.
+func Fields(c *gin.Context, projectID string) []zapcore.Field {
+	req := stackdriver.NewHTTPRequest(c.Request)
+
+	if c.Writer.Written() {
+		req.ResponseStatusCode = c.Writer.Status()
+	}
+
+	fields := []zapcore.Field{stackdriver.LogHTTPRequest(req)}
+	return append(fields, stackdriver.RequestTraceFields(c.Request, projectID)...)
+}
@@ -0,0 +1,33 @@
+package stackdrivergin
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/foo", nil)
+	c.Request.RemoteAddr = "1.2.3.4:5678"
+	c.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	c.String(201, "ok")
+
+	assert.Equal(t, []zapcore.Field{
+		stackdriver.LogHTTPRequest(&stackdriver.HTTPRequest{
+			Method:             "GET",
+			URL:                "http://example.com/foo",
+			ResponseStatusCode: 201,
+			RemoteIP:           "1.2.3.4",
+		}),
+		stackdriver.LogTrace("foo", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		stackdriver.LogSpanID("00f067aa0ba902b7"),
+		stackdriver.LogTraceSampled(true),
+	}, Fields(c, "foo"))
+}
@@ -0,0 +1,12 @@
+module github.com/pablote/zap-stackdriver/stackdrivergin
+
+go 1.14
+
+replace github.com/pablote/zap-stackdriver => ../
+
+require (
+	github.com/gin-gonic/gin v1.6.3
+	github.com/pablote/zap-stackdriver v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.4.0
+	go.uber.org/zap v1.15.0
+)
@@ -0,0 +1,15 @@
+package stackdrivergrpc
+
+import (
+	"context"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap"
+)
+
+// FromContext returns the request-scoped logger of the call ctx belongs to. It
+// is stackdriver.FromContext, so fields added with stackdriver.NewContext are
+// included.
+func FromContext(ctx context.Context) *zap.Logger {
+	return stackdriver.FromContext(ctx)
+}
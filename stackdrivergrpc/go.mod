@@ -0,0 +1,25 @@
+module github.com/pablote/zap-stackdriver/stackdrivergrpc
+
+go 1.21
+
+replace github.com/pablote/zap-stackdriver => ../
+
+require (
+	github.com/pablote/zap-stackdriver v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.9.0
+	go.uber.org/zap v1.15.0
+	google.golang.org/grpc v1.67.3
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
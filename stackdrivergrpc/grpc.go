@@ -0,0 +1,201 @@
+// Package stackdrivergrpc logs the access entries of gRPC calls the way the
+// HTTP middleware of stackdriver does. It's a separate module so that gRPC is
+// only required by those who use it.
+package stackdrivergrpc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metadataTraceParent = "traceparent"
+	metadataCloudTrace  = "x-cloud-trace-context"
+	metadataUserAgent   = "user-agent"
+)
+
+type interceptor struct {
+	logger       *zap.Logger
+	traceProject string
+}
+
+type Option func(*interceptor)
+
+// WithTraceProject adds the trace of each call, read from its traceparent or
+// X-Cloud-Trace-Context metadata, to the request-scoped logger so that every
+// entry of the call is correlated with the trace in projectID.
+func WithTraceProject(projectID string) Option {
+	return func(i *interceptor) {
+		i.traceProject = projectID
+	}
+}
+
+func newInterceptor(logger *zap.Logger, opts []Option) *interceptor {
+	i := &interceptor{logger: logger}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	return i
+}
+
+// UnaryServerInterceptor returns an interceptor which logs an access entry
+// with logger for every unary call once it has been handled. The handler can
+// retrieve the request-scoped logger with FromContext.
+func UnaryServerInterceptor(logger *zap.Logger, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(logger, opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		logger := i.requestLogger(ctx)
+		res, err := handler(stackdriver.WithContext(ctx, logger), req)
+		i.log(ctx, logger, info.FullMethod, err, time.Since(start))
+		return res, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which logs an access entry
+// with logger for every streaming call once it has been handled. The handler
+// can retrieve the request-scoped logger with FromContext.
+func StreamServerInterceptor(logger *zap.Logger, opts ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(logger, opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := ss.Context()
+		logger := i.requestLogger(ctx)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: stackdriver.WithContext(ctx, logger)})
+		i.log(ctx, logger, info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (i *interceptor) requestLogger(ctx context.Context) *zap.Logger {
+	if i.traceProject == "" {
+		return i.logger
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	fields := stackdriver.TraceFields(i.traceProject, first(md, metadataTraceParent), first(md, metadataCloudTrace))
+	return i.logger.With(fields...)
+}
+
+func (i *interceptor) log(ctx context.Context, logger *zap.Logger, method string, err error, latency time.Duration) {
+	code := status.Code(err)
+	ce := logger.Check(codeLevel(code), method)
+
+	if ce == nil {
+		return
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	req := &stackdriver.HTTPRequest{
+		Method:             "POST",
+		URL:                method,
+		UserAgent:          first(md, metadataUserAgent),
+		ResponseStatusCode: httpStatus(code),
+		RemoteIP:           remoteIP(ctx),
+		Latency:            latency,
+	}
+
+	fields := []zapcore.Field{stackdriver.LogHTTPRequest(req), zap.String("grpcCode", code.String())}
+
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	ce.Write(fields...)
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func remoteIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addr := p.Addr.String()
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+// codeLevel returns the level of the access entry of a call which ended with
+// code: InfoLevel when it succeeded, WarnLevel when the client is at fault and
+// ErrorLevel otherwise.
+func codeLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return zapcore.WarnLevel
+	}
+
+	return zapcore.ErrorLevel
+}
+
+// httpStatus returns the HTTP status code equivalent to code, as mapped by
+// https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return 400
+	case codes.DeadlineExceeded:
+		return 504
+	case codes.NotFound:
+		return 404
+	case codes.AlreadyExists, codes.Aborted:
+		return 409
+	case codes.PermissionDenied:
+		return 403
+	case codes.ResourceExhausted:
+		return 429
+	case codes.Unimplemented:
+		return 501
+	case codes.Unavailable:
+		return 503
+	case codes.Unauthenticated:
+		return 401
+	}
+
+	return 500
+}
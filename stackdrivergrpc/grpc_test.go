@@ -0,0 +1,141 @@
+package stackdrivergrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func incomingContext() context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-cloud-trace-context", testTraceID+"/1;o=1",
+		"user-agent", "grpc-go/1.0",
+	))
+
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}})
+}
+
+// withoutSkipped leaves out the fields binding loggers to a context.
+func withoutSkipped(fields []zapcore.Field) []zapcore.Field {
+	var output []zapcore.Field
+
+	for _, f := range fields {
+		if f.Type != zapcore.SkipType {
+			output = append(output, f)
+		}
+	}
+
+	return output
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	intercept := UnaryServerInterceptor(zap.New(core), WithTraceProject("foo"))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/foo.Bar/Baz"}
+	res, err := intercept(incomingContext(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		FromContext(ctx).Info("handling")
+		return nil, status.Error(codes.NotFound, "no baz")
+	})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 2)
+
+	traceFields := []zapcore.Field{
+		stackdriver.LogTrace("foo", testTraceID),
+		stackdriver.LogSpanID("0000000000000001"),
+		stackdriver.LogTraceSampled(true),
+	}
+
+	assert.Equal(t, "handling", entries[0].Message)
+	assert.Equal(t, traceFields, withoutSkipped(entries[0].Context))
+
+	access := entries[1]
+	assert.Equal(t, zapcore.WarnLevel, access.Level)
+	assert.Equal(t, "/foo.Bar/Baz", access.Message)
+	assert.Equal(t, traceFields, access.Context[:3])
+
+	req := access.Context[3].Interface.(*stackdriver.HTTPRequest)
+	assert.True(t, req.Latency >= 0)
+	req.Latency = 0
+
+	assert.Equal(t, &stackdriver.HTTPRequest{
+		Method:             "POST",
+		URL:                "/foo.Bar/Baz",
+		UserAgent:          "grpc-go/1.0",
+		ResponseStatusCode: 404,
+		RemoteIP:           "1.2.3.4",
+	}, req)
+	assert.Equal(t, zap.String("grpcCode", "NotFound"), access.Context[4])
+	assert.Equal(t, zap.Error(err), access.Context[5])
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	intercept := StreamServerInterceptor(zap.New(core))
+
+	info := &grpc.StreamServerInfo{FullMethod: "/foo.Bar/Watch"}
+	err := intercept(nil, &testServerStream{ctx: incomingContext()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		FromContext(ss.Context()).Info("streaming")
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	assert.Nil(t, err)
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "streaming", entries[0].Message)
+	assert.Empty(t, withoutSkipped(entries[0].Context))
+
+	access := entries[1]
+	assert.Equal(t, zapcore.InfoLevel, access.Level)
+
+	req := access.Context[0].Interface.(*stackdriver.HTTPRequest)
+	assert.Equal(t, 200, req.ResponseStatusCode)
+	assert.True(t, req.Latency >= time.Millisecond)
+	assert.Equal(t, zap.String("grpcCode", "OK"), access.Context[1])
+	assert.Len(t, access.Context, 2)
+}
+
+func TestCodeLevel(t *testing.T) {
+	assert.Equal(t, zapcore.InfoLevel, codeLevel(codes.OK))
+	assert.Equal(t, zapcore.WarnLevel, codeLevel(codes.InvalidArgument))
+	assert.Equal(t, zapcore.ErrorLevel, codeLevel(codes.Internal))
+	assert.Equal(t, zapcore.ErrorLevel, codeLevel(codes.Unavailable))
+}
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx := stackdriver.WithContext(context.Background(), zap.New(core))
+
+	FromContext(stackdriver.NewContext(ctx, stackdriver.LogUser("foo"))).Info("")
+	assert.Equal(t, []zapcore.Field{stackdriver.LogUser("foo")}, withoutSkipped(logs.AllUntimed()[0].Context))
+}
@@ -0,0 +1,221 @@
+package stackdriverlogging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/logging"
+	vkit "cloud.google.com/go/logging/apiv2"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/googleapis/gax-go/v2"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// EntrySink receives the entries of an export Core as LogEntry protos, such
+// as to publish them to Pub/Sub, write them to a file or send them to the
+// Logging API. Flush is called by Sync.
+type EntrySink interface {
+	WriteEntry(e *loggingpb.LogEntry) error
+	Flush() error
+}
+
+// SinkFunc is an EntrySink calling itself for every entry, with nothing to
+// flush, such as to publish entries to Pub/Sub:
+//
+//	sink := stackdriverlogging.SinkFunc(func(e *loggingpb.LogEntry) error {
+//		data, err := proto.Marshal(e)
+//		// ...
+//		topic.Publish(ctx, &pubsub.Message{Data: data})
+//		return nil
+//	})
+type SinkFunc func(e *loggingpb.LogEntry) error
+
+func (f SinkFunc) WriteEntry(e *loggingpb.LogEntry) error {
+	return f(e)
+}
+
+func (f SinkFunc) Flush() error {
+	return nil
+}
+
+// NewExportCore returns a Core converting the entries enabled by enab into
+// LogEntry protos of the log logName, such as "projects/my-project/logs/my-log",
+// and writing them to sink, configured by opts.
+//
+// As with NewAPICore, the severity, timestamp, HTTP request, labels,
+// operation, trace and source location of entries are written as the fields
+// of the LogEntry, and the rest of the entry as its JSON payload.
+func NewExportCore(sink EntrySink, logName string, enab zapcore.LevelEnabler, opts ...stackdriver.Option) *stackdriver.Core {
+	return stackdriver.NewCore(newExportCore(sink, logName, enab), opts...)
+}
+
+func newExportCore(sink EntrySink, logName string, enab zapcore.LevelEnabler) *exportCore {
+	parent := logName
+
+	if i := strings.Index(logName, "/logs/"); i >= 0 {
+		parent = logName[:i]
+	}
+
+	return &exportCore{
+		LevelEnabler: enab,
+		sink:         sink,
+		logName:      logName,
+		parent:       parent,
+	}
+}
+
+type exportCore struct {
+	zapcore.LevelEnabler
+
+	sink    EntrySink
+	logName string
+	parent  string
+	fields  []zapcore.Field
+}
+
+func (c *exportCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *exportCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+func (c *exportCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	le := toEntry(entry, c.fields, fields)
+
+	// The trace of a LogEntry is a resource name, which the Core only writes
+	// when given its project.
+	if le.Trace != "" && !strings.Contains(le.Trace, "/traces/") {
+		le.Trace = c.parent + "/traces/" + le.Trace
+	}
+
+	e, err := logging.ToLogEntry(le, c.parent)
+
+	if err != nil {
+		return err
+	}
+
+	e.LogName = c.logName
+	return c.sink.WriteEntry(e)
+}
+
+// Sync flushes the sink.
+func (c *exportCore) Sync() error {
+	return c.sink.Flush()
+}
+
+// NewWriterSink returns an EntrySink writing entries to ws as JSON, one per
+// line, such as to a file read by an agent. Flush syncs ws.
+func NewWriterSink(ws zapcore.WriteSyncer) EntrySink {
+	return &writerSink{ws: ws}
+}
+
+type writerSink struct {
+	mu sync.Mutex
+	ws zapcore.WriteSyncer
+}
+
+func (s *writerSink) WriteEntry(e *loggingpb.LogEntry) error {
+	b, err := protojson.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.ws.Write(append(b, '\n'))
+	return err
+}
+
+func (s *writerSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ws.Sync()
+}
+
+// logEntriesWriter is implemented by *vkit.Client.
+type logEntriesWriter interface {
+	WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error)
+}
+
+// NewAPISink returns an EntrySink sending entries to the Logging API through
+// client in batches of batchSize, and the entries left by Flush. Entries
+// without a resource are written with resource, or with the global resource
+// if it's nil.
+func NewAPISink(client *vkit.Client, resource *monitoredres.MonitoredResource, batchSize int) EntrySink {
+	return newAPISink(client, resource, batchSize)
+}
+
+func newAPISink(client logEntriesWriter, resource *monitoredres.MonitoredResource, batchSize int) *apiSink {
+	if resource == nil {
+		resource = &monitoredres.MonitoredResource{Type: "global"}
+	}
+
+	return &apiSink{
+		client:    client,
+		resource:  resource,
+		batchSize: batchSize,
+	}
+}
+
+type apiSink struct {
+	mu        sync.Mutex
+	client    logEntriesWriter
+	resource  *monitoredres.MonitoredResource
+	batchSize int
+	entries   []*loggingpb.LogEntry
+}
+
+func (s *apiSink) WriteEntry(e *loggingpb.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+
+	if len(s.entries) < s.batchSize {
+		return nil
+	}
+
+	return s.flush()
+}
+
+func (s *apiSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+func (s *apiSink) flush() error {
+	if len(s.entries) == 0 {
+		return nil
+	}
+
+	req := &loggingpb.WriteLogEntriesRequest{
+		Resource: s.resource,
+		Entries:  s.entries,
+	}
+
+	s.entries = nil
+
+	if _, err := s.client.WriteLogEntries(context.Background(), req); err != nil {
+		return fmt.Errorf("write %d entries: %w", len(req.Entries), err)
+	}
+
+	return nil
+}
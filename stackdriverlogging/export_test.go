@@ -0,0 +1,139 @@
+package stackdriverlogging
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/googleapis/gax-go/v2"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type recordingSink struct {
+	entries []*loggingpb.LogEntry
+	flushes int
+}
+
+func (s *recordingSink) WriteEntry(e *loggingpb.LogEntry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *recordingSink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+func TestExportCore(t *testing.T) {
+	sink := &recordingSink{}
+	logger := zap.New(NewExportCore(sink, "projects/proj/logs/app", zapcore.InfoLevel,
+		stackdriver.WithLabels(map[string]string{"team": "foo"}),
+		stackdriver.WithSourceLocation(true),
+	), zap.AddCaller())
+
+	logger.Debug("dropped")
+	logger.With(zap.String("foo", "bar")).Warn("hello",
+		stackdriver.LogHTTPRequest(&stackdriver.HTTPRequest{
+			Method:             "GET",
+			URL:                "https://example.com/foo",
+			ResponseStatusCode: 503,
+			Latency:            3500 * time.Millisecond,
+		}),
+		stackdriver.LogTraceID("4bf92f3577b34da6a3ce929d0e0e4736"),
+		stackdriver.LogOperation("op", "prod", true, false),
+	)
+
+	require.Len(t, sink.entries, 1)
+	e := sink.entries[0]
+
+	assert.Equal(t, "projects/proj/logs/app", e.LogName)
+	assert.Equal(t, ltype.LogSeverity_WARNING, e.Severity)
+	assert.WithinDuration(t, time.Now(), e.Timestamp.AsTime(), time.Second)
+	assert.Equal(t, map[string]string{"team": "foo"}, e.Labels)
+	assert.Equal(t, "projects/proj/traces/4bf92f3577b34da6a3ce929d0e0e4736", e.Trace)
+	assert.Equal(t, "op", e.Operation.Id)
+	assert.True(t, e.Operation.First)
+
+	require.NotNil(t, e.SourceLocation)
+	assert.Contains(t, e.SourceLocation.File, "export_test.go")
+
+	require.NotNil(t, e.HttpRequest)
+	assert.Equal(t, "GET", e.HttpRequest.RequestMethod)
+	assert.Equal(t, "https://example.com/foo", e.HttpRequest.RequestUrl)
+	assert.Equal(t, int32(503), e.HttpRequest.Status)
+	assert.Equal(t, 3500*time.Millisecond, e.HttpRequest.Latency.AsDuration())
+
+	payload := e.GetJsonPayload().AsMap()
+	assert.Equal(t, "hello", payload["message"])
+	assert.Equal(t, "bar", payload["foo"])
+	assert.NotContains(t, payload, keyLabels)
+	assert.NotContains(t, payload, keyTrace)
+
+	require.Nil(t, logger.Sync())
+	assert.Equal(t, 1, sink.flushes)
+}
+
+func TestExportCore_SinkError(t *testing.T) {
+	core := NewExportCore(SinkFunc(func(*loggingpb.LogEntry) error {
+		return errors.New("boom")
+	}), "projects/proj/logs/app", zapcore.InfoLevel)
+
+	err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}, nil)
+	assert.EqualError(t, err, "boom")
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf strings.Builder
+	logger := zap.New(NewExportCore(NewWriterSink(zapcore.AddSync(&buf)), "projects/proj/logs/app", zapcore.InfoLevel))
+
+	logger.Info("hello")
+	logger.Error("world")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var e loggingpb.LogEntry
+
+	require.Nil(t, protojson.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, ltype.LogSeverity_ERROR, e.Severity)
+	assert.Equal(t, "world", e.GetJsonPayload().AsMap()["message"])
+}
+
+type recordingEntriesWriter struct {
+	reqs []*loggingpb.WriteLogEntriesRequest
+}
+
+func (w *recordingEntriesWriter) WriteLogEntries(_ context.Context, req *loggingpb.WriteLogEntriesRequest, _ ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.reqs = append(w.reqs, req)
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+func TestAPISink(t *testing.T) {
+	client := &recordingEntriesWriter{}
+	logger := zap.New(NewExportCore(newAPISink(client, nil, 2), "projects/proj/logs/app", zapcore.InfoLevel))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("hello")
+	}
+
+	require.Len(t, client.reqs, 1)
+	assert.Len(t, client.reqs[0].Entries, 2)
+	assert.Equal(t, &monitoredres.MonitoredResource{Type: "global"}, client.reqs[0].Resource)
+
+	require.Nil(t, logger.Sync())
+	require.Len(t, client.reqs, 2)
+	assert.Len(t, client.reqs[1].Entries, 1)
+
+	require.Nil(t, logger.Sync())
+	assert.Len(t, client.reqs, 2)
+}
@@ -0,0 +1,238 @@
+// Package stackdriverlogging writes log entries through the Cloud Logging
+// API instead of an encoder, for processes without a logging agent reading
+// their output, such as on VMs or on premises, or converts them into LogEntry
+// protos for an EntrySink, such as a Pub/Sub topic feeding a pipeline.
+package stackdriverlogging
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	keyContext        = "context"
+	keyHTTPRequest    = "httpRequest"
+	keyLabels         = "logging.googleapis.com/labels"
+	keyOperation      = "logging.googleapis.com/operation"
+	keyTrace          = "logging.googleapis.com/trace"
+	keySpanID         = "logging.googleapis.com/spanId"
+	keyTraceSampled   = "logging.googleapis.com/trace_sampled"
+	keySourceLocation = "logging.googleapis.com/sourceLocation"
+	keyInsertID       = "logging.googleapis.com/insertId"
+)
+
+// entryLogger is implemented by *logging.Logger.
+type entryLogger interface {
+	Log(logging.Entry)
+	Flush() error
+}
+
+// NewAPICore returns a Core writing the entries enabled by enab to the log
+// logName of client, configured by opts. The logger of client batches the
+// entries, which are sent at the latest by Sync.
+//
+// The HTTP request, labels, operation, trace and source location of entries
+// are written as the fields of the LogEntry rather than as payload keys.
+func NewAPICore(client *logging.Client, logName string, enab zapcore.LevelEnabler, opts ...stackdriver.Option) *stackdriver.Core {
+	return stackdriver.NewCore(newAPICore(client.Logger(logName), enab), opts...)
+}
+
+func newAPICore(logger entryLogger, enab zapcore.LevelEnabler) *apiCore {
+	return &apiCore{
+		LevelEnabler: enab,
+		logger:       logger,
+	}
+}
+
+type apiCore struct {
+	zapcore.LevelEnabler
+
+	logger entryLogger
+	fields []zapcore.Field
+}
+
+func (c *apiCore) With(fields []zapcore.Field) zapcore.Core {
+	return &apiCore{
+		LevelEnabler: c.LevelEnabler,
+		logger:       c.logger,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *apiCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+func (c *apiCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.logger.Log(toEntry(entry, c.fields, fields))
+	return nil
+}
+
+// Sync sends the entries batched by the logger.
+func (c *apiCore) Sync() error {
+	return c.logger.Flush()
+}
+
+// toEntry converts entry and the fields of its core, followed by its own,
+// into an entry of the logging client.
+func toEntry(entry zapcore.Entry, fields ...[]zapcore.Field) logging.Entry {
+	enc := zapcore.NewMapObjectEncoder()
+
+	for _, fs := range fields {
+		for _, f := range fs {
+			f.AddTo(enc)
+		}
+	}
+
+	payload := enc.Fields
+	e := logging.Entry{
+		Timestamp: entry.Time,
+		Severity:  logging.ParseSeverity(stackdriver.Severity(entry.Level)),
+	}
+
+	if labels, ok := payload[keyLabels].(map[string]interface{}); ok {
+		e.Labels = make(map[string]string, len(labels))
+
+		for k, v := range labels {
+			e.Labels[k] = fmt.Sprint(v)
+		}
+	}
+
+	if op, ok := payload[keyOperation].(map[string]interface{}); ok {
+		e.Operation = &loggingpb.LogEntryOperation{
+			Id:       stringValue(op["id"]),
+			Producer: stringValue(op["producer"]),
+			First:    op["first"] == true,
+			Last:     op["last"] == true,
+		}
+	}
+
+	if loc, ok := payload[keySourceLocation].(map[string]interface{}); ok {
+		line, _ := strconv.ParseInt(stringValue(loc["line"]), 10, 64)
+		e.SourceLocation = &loggingpb.LogEntrySourceLocation{
+			File:     stringValue(loc["file"]),
+			Line:     line,
+			Function: stringValue(loc["function"]),
+		}
+	}
+
+	e.Trace = stringValue(payload[keyTrace])
+	e.SpanID = stringValue(payload[keySpanID])
+	e.TraceSampled = payload[keyTraceSampled] == true
+	e.InsertID = stringValue(payload[keyInsertID])
+
+	for _, k := range []string{keyHTTPRequest, keyLabels, keyOperation, keySourceLocation, keyTrace, keySpanID, keyTraceSampled, keyInsertID} {
+		delete(payload, k)
+	}
+
+	if ctx, ok := payload[keyContext].(map[string]interface{}); ok {
+		if req, ok := ctx[keyHTTPRequest].(map[string]interface{}); ok {
+			e.HTTPRequest = httpRequest(req)
+			delete(ctx, keyHTTPRequest)
+		}
+
+		if len(ctx) == 0 {
+			delete(payload, keyContext)
+		}
+	}
+
+	config := stackdriver.EncoderConfig
+	payload[config.MessageKey] = entry.Message
+
+	if entry.LoggerName != "" {
+		payload[config.NameKey] = entry.LoggerName
+	}
+
+	if entry.Caller.Defined {
+		payload[config.CallerKey] = entry.Caller.TrimmedPath()
+	}
+
+	if entry.Stack != "" {
+		payload[config.StacktraceKey] = entry.Stack
+	}
+
+	e.Payload = jsonValue(payload)
+	return e
+}
+
+// httpRequest converts the httpRequest object of a context back into a
+// request, which the logging client needs to write it.
+func httpRequest(m map[string]interface{}) *logging.HTTPRequest {
+	u, err := url.Parse(stringValue(m["url"]))
+
+	if err != nil {
+		u = &url.URL{}
+	}
+
+	r := &http.Request{
+		Method: stringValue(m["method"]),
+		URL:    u,
+		Header: http.Header{},
+	}
+
+	if ua := stringValue(m["userAgent"]); ua != "" {
+		r.Header.Set("User-Agent", ua)
+	}
+
+	if ref := stringValue(m["referrer"]); ref != "" {
+		r.Header.Set("Referer", ref)
+	}
+
+	req := &logging.HTTPRequest{
+		Request:  r,
+		RemoteIP: stringValue(m["remoteIp"]),
+	}
+
+	if status, ok := m["responseStatusCode"].(int); ok {
+		req.Status = status
+	}
+
+	req.Latency, _ = time.ParseDuration(stringValue(m["latency"]))
+	req.RequestSize, _ = strconv.ParseInt(stringValue(m["requestSize"]), 10, 64)
+	req.ResponseSize, _ = strconv.ParseInt(stringValue(m["responseSize"]), 10, 64)
+	return req
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// jsonValue converts the values of a MapObjectEncoder which the logging
+// client can't marshal as JSON, or would marshal differently than the
+// stackdriver encoder config, such as durations which are written in
+// milliseconds.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = jsonValue(elem)
+		}
+
+		return v
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = jsonValue(elem)
+		}
+
+		return v
+	case time.Duration:
+		return int64(v / time.Millisecond)
+	case complex128, complex64:
+		return fmt.Sprint(v)
+	}
+
+	return v
+}
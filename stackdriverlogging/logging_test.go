@@ -0,0 +1,113 @@
+package stackdriverlogging
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type recordingLogger struct {
+	entries []logging.Entry
+	flushes int
+}
+
+func (l *recordingLogger) Log(e logging.Entry) {
+	l.entries = append(l.entries, e)
+}
+
+func (l *recordingLogger) Flush() error {
+	l.flushes++
+	return nil
+}
+
+func TestAPICore(t *testing.T) {
+	rec := &recordingLogger{}
+	logger := zap.New(stackdriver.NewCore(newAPICore(rec, zapcore.InfoLevel),
+		stackdriver.WithLabels(map[string]string{"team": "foo"}),
+		stackdriver.WithSourceLocation(true),
+	), zap.AddCaller())
+
+	logger.Debug("dropped")
+	logger.With(zap.String("foo", "bar")).Warn("hello",
+		zap.Duration("wait", 1500*time.Millisecond),
+		stackdriver.LogUser("baz"),
+		stackdriver.LogHTTPRequest(&stackdriver.HTTPRequest{
+			Method:             "GET",
+			URL:                "https://example.com/foo",
+			UserAgent:          "qux",
+			ResponseStatusCode: 503,
+			RemoteIP:           "1.2.3.4",
+			Latency:            3500 * time.Millisecond,
+			ResponseSize:       42,
+		}),
+		stackdriver.LogTraceSpan("proj", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true),
+		stackdriver.LogOperation("op", "prod", true, false),
+	)
+
+	require.Len(t, rec.entries, 1)
+	e := rec.entries[0]
+
+	assert.Equal(t, logging.Warning, e.Severity)
+	assert.WithinDuration(t, time.Now(), e.Timestamp, time.Second)
+	assert.Equal(t, map[string]string{"team": "foo"}, e.Labels)
+	assert.Equal(t, "projects/proj/traces/4bf92f3577b34da6a3ce929d0e0e4736", e.Trace)
+	assert.Equal(t, "00f067aa0ba902b7", e.SpanID)
+	assert.True(t, e.TraceSampled)
+	assert.Equal(t, &loggingpb.LogEntryOperation{Id: "op", Producer: "prod", First: true}, e.Operation)
+
+	require.NotNil(t, e.SourceLocation)
+	assert.Contains(t, e.SourceLocation.File, "logging_test.go")
+	assert.NotZero(t, e.SourceLocation.Line)
+	assert.Contains(t, e.SourceLocation.Function, "TestAPICore")
+
+	require.NotNil(t, e.HTTPRequest)
+	assert.Equal(t, "GET", e.HTTPRequest.Request.Method)
+	assert.Equal(t, "https://example.com/foo", e.HTTPRequest.Request.URL.String())
+	assert.Equal(t, "qux", e.HTTPRequest.Request.UserAgent())
+	assert.Equal(t, 503, e.HTTPRequest.Status)
+	assert.Equal(t, "1.2.3.4", e.HTTPRequest.RemoteIP)
+	assert.Equal(t, 3500*time.Millisecond, e.HTTPRequest.Latency)
+	assert.Equal(t, int64(42), e.HTTPRequest.ResponseSize)
+
+	payload := e.Payload.(map[string]interface{})
+	_, err := json.Marshal(payload)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", payload["message"])
+	assert.Equal(t, "bar", payload["foo"])
+	assert.Equal(t, int64(1500), payload["wait"])
+	assert.Equal(t, map[string]interface{}{"user": "baz"}, payload["context"])
+	assert.Contains(t, payload, "caller")
+	assert.NotContains(t, payload, keyLabels)
+	assert.NotContains(t, payload, keyTrace)
+	assert.NotContains(t, payload, keySourceLocation)
+	assert.NotContains(t, payload, keyHTTPRequest)
+
+	require.Nil(t, logger.Sync())
+	assert.Equal(t, 1, rec.flushes)
+}
+
+func TestAPICore_Error(t *testing.T) {
+	rec := &recordingLogger{}
+	logger := zap.New(stackdriver.NewCore(newAPICore(rec, zapcore.DebugLevel)), zap.AddStacktrace(zapcore.ErrorLevel))
+
+	logger.Error("failed", zap.Error(errors.New("boom")))
+
+	require.Len(t, rec.entries, 1)
+	e := rec.entries[0]
+	payload := e.Payload.(map[string]interface{})
+
+	assert.Equal(t, logging.Error, e.Severity)
+	assert.Nil(t, e.HTTPRequest)
+	assert.Equal(t, "boom", payload["error"])
+	assert.Contains(t, payload["stacktrace"], "TestAPICore_Error")
+	assert.NotContains(t, payload, "context")
+}
@@ -0,0 +1,96 @@
+// Package stackdriverpubsub publishes encoded log entries to a Pub/Sub topic,
+// for routing them through custom pipelines.
+//
+// The Pub/Sub client is injected through the Publisher interface, so that
+// this package doesn't depend on it. A *pubsub.Topic can be adapted with:
+//
+//	type topic struct{ *pubsub.Topic }
+//
+//	func (t topic) Publish(ctx context.Context, data []byte) stackdriverpubsub.Result {
+//		return t.Topic.Publish(ctx, &pubsub.Message{Data: data})
+//	}
+package stackdriverpubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is the result of publishing a message, as returned by
+// *pubsub.PublishResult.
+type Result interface {
+	Ready() <-chan struct{}
+	Get(ctx context.Context) (serverID string, err error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, data []byte) Result
+}
+
+// WriteSyncer is a zapcore.WriteSyncer publishing every write as a message.
+type WriteSyncer struct {
+	publisher Publisher
+
+	mu      sync.Mutex
+	pending []Result
+	err     error
+}
+
+func NewWriteSyncer(publisher Publisher) *WriteSyncer {
+	return &WriteSyncer{
+		publisher: publisher,
+	}
+}
+
+// Write publishes p without waiting for the result, which is reported by a
+// later call to Sync.
+func (w *WriteSyncer) Write(p []byte) (int, error) {
+	// zap reuses the buffer once Write returns.
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	res := w.publisher.Publish(context.Background(), data)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, res)
+	w.collect(false)
+	return len(p), nil
+}
+
+// Sync waits for every pending message to be published and returns the first
+// error since the previous call to Sync.
+func (w *WriteSyncer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.collect(true)
+
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// collect drops the pending results which are ready, or all of them if wait
+// is true, keeping the first error.
+func (w *WriteSyncer) collect(wait bool) {
+	pending := w.pending[:0]
+
+	for _, res := range w.pending {
+		if !wait {
+			select {
+			case <-res.Ready():
+			default:
+				pending = append(pending, res)
+				continue
+			}
+		}
+
+		if _, err := res.Get(context.Background()); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+
+	w.pending = pending
+}
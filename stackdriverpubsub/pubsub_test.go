@@ -0,0 +1,121 @@
+package stackdriverpubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type fakeResult struct {
+	ready chan struct{}
+	err   error
+}
+
+func (r *fakeResult) Ready() <-chan struct{} {
+	return r.ready
+}
+
+func (r *fakeResult) Get(ctx context.Context) (string, error) {
+	<-r.ready
+	return "id", r.err
+}
+
+type fakePublisher struct {
+	messages [][]byte
+	results  []*fakeResult
+	err      error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, data []byte) Result {
+	res := &fakeResult{ready: make(chan struct{}), err: p.err}
+	p.messages = append(p.messages, data)
+	p.results = append(p.results, res)
+	return res
+}
+
+func (p *fakePublisher) publishAll() {
+	for _, res := range p.results {
+		select {
+		case <-res.ready:
+		default:
+			close(res.ready)
+		}
+	}
+}
+
+func TestWriteSyncer(t *testing.T) {
+	publisher := &fakePublisher{}
+	ws := NewWriteSyncer(publisher)
+	enc := zapcore.NewJSONEncoder(stackdriver.EncoderConfig)
+	logger := zap.New(stackdriver.NewCore(zapcore.NewCore(enc, ws, zapcore.DebugLevel)))
+
+	logger.Info("foo")
+	logger.Info("bar")
+
+	require.Len(t, publisher.messages, 2)
+
+	var actual struct {
+		Message string `json:"message"`
+	}
+
+	require.Nil(t, json.Unmarshal(publisher.messages[0], &actual))
+	assert.Equal(t, "foo", actual.Message)
+	require.Nil(t, json.Unmarshal(publisher.messages[1], &actual))
+	assert.Equal(t, "bar", actual.Message)
+
+	done := make(chan error)
+
+	go func() {
+		done <- logger.Sync()
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Sync returned before the messages were published")
+	default:
+	}
+
+	publisher.publishAll()
+	assert.Nil(t, <-done)
+	assert.Empty(t, ws.pending)
+}
+
+func TestWriteSyncer_Error(t *testing.T) {
+	publisher := &fakePublisher{err: errors.New("random error")}
+	ws := NewWriteSyncer(publisher)
+
+	_, err := ws.Write([]byte("foo"))
+	require.Nil(t, err)
+
+	publisher.publishAll()
+	publisher.err = nil
+
+	_, err = ws.Write([]byte("bar"))
+	require.Nil(t, err)
+	assert.Len(t, ws.pending, 1)
+
+	publisher.publishAll()
+	assert.EqualError(t, ws.Sync(), "random error")
+	assert.Nil(t, ws.Sync())
+}
+
+func TestWriteSyncer_CopiesBuffer(t *testing.T) {
+	publisher := &fakePublisher{}
+	ws := NewWriteSyncer(publisher)
+	buf := bytes.NewBufferString("foo")
+
+	_, err := ws.Write(buf.Bytes())
+	require.Nil(t, err)
+
+	buf.Reset()
+	buf.WriteString("bar")
+	assert.Equal(t, "foo", string(publisher.messages[0]))
+}
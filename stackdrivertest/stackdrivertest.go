@@ -0,0 +1,296 @@
+// Package stackdrivertest records the entries written by a stackdriver Core
+// as they are read by Cloud Logging, for tests to make assertions about them
+// without parsing JSON.
+package stackdrivertest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is an entry as written by a stackdriver Core.
+type Entry struct {
+	Severity       string
+	Timestamp      time.Time
+	Message        string
+	ServiceContext *stackdriver.ServiceContext
+	User           string
+	HTTPRequest    *stackdriver.HTTPRequest
+	ReportLocation *stackdriver.ReportLocation
+	Labels         map[string]string
+	Operation      *stackdriver.Operation
+	Trace          string
+	SpanID         string
+	TraceSampled   bool
+	SourceLocation *SourceLocation
+	InsertID       string
+
+	// Payload holds the other keys of the entry, as decoded by
+	// encoding/json.
+	Payload map[string]interface{}
+}
+
+// SourceLocation is the logging.googleapis.com/sourceLocation of an entry.
+type SourceLocation struct {
+	File     string `json:"file"`
+	Line     string `json:"line"`
+	Function string `json:"function"`
+}
+
+// rawEntry is the JSON of an entry, whose other keys are its payload.
+type rawEntry struct {
+	Severity       string                      `json:"severity"`
+	Timestamp      time.Time                   `json:"timestamp"`
+	Message        string                      `json:"message"`
+	ServiceContext *stackdriver.ServiceContext `json:"serviceContext"`
+	Context        *struct {
+		User        string `json:"user"`
+		HTTPRequest *struct {
+			stackdriver.HTTPRequest
+
+			Latency string `json:"latency"`
+		} `json:"httpRequest"`
+		ReportLocation *stackdriver.ReportLocation `json:"reportLocation"`
+	} `json:"context"`
+	Labels         map[string]string      `json:"logging.googleapis.com/labels"`
+	Operation      *stackdriver.Operation `json:"logging.googleapis.com/operation"`
+	Trace          string                 `json:"logging.googleapis.com/trace"`
+	SpanID         string                 `json:"logging.googleapis.com/spanId"`
+	TraceSampled   bool                   `json:"logging.googleapis.com/trace_sampled"`
+	SourceLocation *SourceLocation        `json:"logging.googleapis.com/sourceLocation"`
+	InsertID       string                 `json:"logging.googleapis.com/insertId"`
+}
+
+var entryKeys = []string{
+	"severity", "timestamp", "message", "serviceContext", "context",
+	"logging.googleapis.com/labels", "logging.googleapis.com/operation",
+	"logging.googleapis.com/trace", "logging.googleapis.com/spanId",
+	"logging.googleapis.com/trace_sampled", "logging.googleapis.com/sourceLocation",
+	"logging.googleapis.com/insertId", "httpRequest",
+}
+
+func decodeEntry(b []byte) (Entry, error) {
+	var raw rawEntry
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return Entry{}, err
+	}
+
+	var payload map[string]interface{}
+
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return Entry{}, err
+	}
+
+	for _, k := range entryKeys {
+		delete(payload, k)
+	}
+
+	entry := Entry{
+		Severity:       raw.Severity,
+		Timestamp:      raw.Timestamp,
+		Message:        raw.Message,
+		ServiceContext: raw.ServiceContext,
+		Labels:         raw.Labels,
+		Operation:      raw.Operation,
+		Trace:          raw.Trace,
+		SpanID:         raw.SpanID,
+		TraceSampled:   raw.TraceSampled,
+		SourceLocation: raw.SourceLocation,
+		InsertID:       raw.InsertID,
+		Payload:        payload,
+	}
+
+	if ctx := raw.Context; ctx != nil {
+		entry.User = ctx.User
+		entry.ReportLocation = ctx.ReportLocation
+
+		if req := ctx.HTTPRequest; req != nil {
+			entry.HTTPRequest = &req.HTTPRequest
+
+			if req.Latency != "" {
+				latency, err := time.ParseDuration(req.Latency)
+
+				if err != nil {
+					return Entry{}, err
+				}
+
+				entry.HTTPRequest.Latency = latency
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+// Entries are the entries recorded by the Core returned by New. They are safe
+// for concurrent use.
+type Entries struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// New returns a Core configured by opts, writing the entries at the levels
+// enabled by enab to the returned Entries.
+func New(enab zapcore.LevelEnabler, opts ...stackdriver.Option) (*stackdriver.Core, *Entries) {
+	entries := &Entries{}
+	return stackdriver.NewJSONCore(zapcore.AddSync(entries), enab, opts...), entries
+}
+
+// Write decodes an entry written by the Core.
+func (e *Entries) Write(p []byte) (int, error) {
+	entry, err := decodeEntry(p)
+
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.entries = append(e.entries, entry)
+	return len(p), nil
+}
+
+// All returns a copy of the entries, in the order they were written.
+func (e *Entries) All() []Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Entry(nil), e.entries...)
+}
+
+// Len returns the number of entries.
+func (e *Entries) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.entries)
+}
+
+// TakeAll returns the entries and removes them.
+func (e *Entries) TakeAll() []Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entries := e.entries
+	e.entries = nil
+	return entries
+}
+
+// Filter returns the entries keep returns true for.
+func (e *Entries) Filter(keep func(Entry) bool) *Entries {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	filtered := &Entries{}
+
+	for _, entry := range e.entries {
+		if keep(entry) {
+			filtered.entries = append(filtered.entries, entry)
+		}
+	}
+
+	return filtered
+}
+
+// FilterSeverity returns the entries with severity, such as "ERROR".
+func (e *Entries) FilterSeverity(severity string) *Entries {
+	return e.Filter(func(entry Entry) bool {
+		return entry.Severity == severity
+	})
+}
+
+// FilterMessage returns the entries with msg.
+func (e *Entries) FilterMessage(msg string) *Entries {
+	return e.Filter(func(entry Entry) bool {
+		return entry.Message == msg
+	})
+}
+
+// FilterLabel returns the entries labelled with key set to value.
+func (e *Entries) FilterLabel(key, value string) *Entries {
+	return e.Filter(func(entry Entry) bool {
+		v, ok := entry.Labels[key]
+		return ok && v == value
+	})
+}
+
+// FilterTrace returns the entries of the trace, formatted as
+// "projects/PROJECT_ID/traces/TRACE_ID".
+func (e *Entries) FilterTrace(trace string) *Entries {
+	return e.Filter(func(entry Entry) bool {
+		return entry.Trace == trace
+	})
+}
+
+// AssertSeverity reports an error to t unless entry has severity.
+func AssertSeverity(t testing.TB, entry Entry, severity string) bool {
+	t.Helper()
+
+	if entry.Severity != severity {
+		t.Errorf("entry %q has severity %s, not %s", entry.Message, entry.Severity, severity)
+		return false
+	}
+
+	return true
+}
+
+// AssertTrace reports an error to t unless entry belongs to the trace
+// traceID of projectID.
+func AssertTrace(t testing.TB, entry Entry, projectID, traceID string) bool {
+	t.Helper()
+
+	if expected := "projects/" + projectID + "/traces/" + traceID; entry.Trace != expected {
+		t.Errorf("entry %q has trace %q, not %q", entry.Message, entry.Trace, expected)
+		return false
+	}
+
+	return true
+}
+
+// AssertServiceContext reports an error to t unless entry has the
+// serviceContext of service at version.
+func AssertServiceContext(t testing.TB, entry Entry, service, version string) bool {
+	t.Helper()
+
+	expected := &stackdriver.ServiceContext{Service: service, Version: version}
+
+	if !reflect.DeepEqual(entry.ServiceContext, expected) {
+		t.Errorf("entry %q has serviceContext %+v, not %+v", entry.Message, entry.ServiceContext, expected)
+		return false
+	}
+
+	return true
+}
+
+// AssertHTTPRequest reports an error to t unless entry has the httpRequest
+// expected. The latency isn't compared when expected has none, since it's
+// rarely known in advance.
+func AssertHTTPRequest(t testing.TB, entry Entry, expected *stackdriver.HTTPRequest) bool {
+	t.Helper()
+
+	if entry.HTTPRequest == nil {
+		t.Errorf("entry %q has no httpRequest", entry.Message)
+		return false
+	}
+
+	actual := *entry.HTTPRequest
+
+	if expected.Latency == 0 {
+		actual.Latency = 0
+	}
+
+	if !reflect.DeepEqual(&actual, expected) {
+		t.Errorf("entry %q has httpRequest %+v, not %+v", entry.Message, &actual, expected)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package stackdrivertest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	stackdriver "github.com/pablote/zap-stackdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestNew(t *testing.T) {
+	core, entries := New(zapcore.InfoLevel, stackdriver.WithServiceContext("foo", "1.2.3"))
+	logger := zap.New(core).With(stackdriver.LogTrace("bar", testTraceID), stackdriver.LogUser("alice"))
+
+	req := &stackdriver.HTTPRequest{
+		Method:             "GET",
+		URL:                "/foo",
+		ResponseStatusCode: 200,
+		RemoteIP:           "1.2.3.4",
+		Latency:            1500 * time.Millisecond,
+		ResponseSize:       42,
+	}
+
+	logger.Debug("dropped")
+	logger.Info("served", stackdriver.LogHTTPRequest(req), zap.Int("n", 1))
+	logger.Error("failed", zap.Error(errors.New("boom")), stackdriver.LogLabels(map[string]string{"job": "baz"}))
+
+	require.Equal(t, 2, entries.Len())
+
+	served := entries.FilterMessage("served").All()
+	require.Len(t, served, 1)
+	assert.True(t, AssertSeverity(t, served[0], "INFO"))
+	assert.True(t, AssertTrace(t, served[0], "bar", testTraceID))
+	assert.True(t, AssertServiceContext(t, served[0], "foo", "1.2.3"))
+	assert.True(t, AssertHTTPRequest(t, served[0], req))
+	assert.Equal(t, "alice", served[0].User)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, served[0].Payload)
+	assert.WithinDuration(t, time.Now(), served[0].Timestamp, time.Minute)
+
+	failed := entries.FilterSeverity("ERROR").All()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "failed", failed[0].Message)
+	assert.Equal(t, "boom", failed[0].Payload["error"])
+
+	assert.Equal(t, 1, entries.FilterLabel("job", "baz").Len())
+	assert.Equal(t, 2, entries.FilterTrace("projects/bar/traces/"+testTraceID).Len())
+	assert.Len(t, entries.TakeAll(), 2)
+	assert.Equal(t, 0, entries.Len())
+}
+
+// recordingT records the errors reported to it.
+type recordingT struct {
+	testing.TB
+
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	entry := Entry{Message: "foo", Severity: "INFO", HTTPRequest: &stackdriver.HTTPRequest{Method: "GET", Latency: time.Second}}
+	rt := &recordingT{TB: t}
+
+	assert.False(t, AssertSeverity(rt, entry, "ERROR"))
+	assert.False(t, AssertTrace(rt, entry, "bar", testTraceID))
+	assert.False(t, AssertServiceContext(rt, entry, "foo", ""))
+	assert.False(t, AssertHTTPRequest(rt, entry, &stackdriver.HTTPRequest{Method: "POST"}))
+	assert.False(t, AssertHTTPRequest(rt, Entry{}, &stackdriver.HTTPRequest{}))
+	assert.True(t, AssertHTTPRequest(rt, entry, &stackdriver.HTTPRequest{Method: "GET"}))
+
+	assert.Len(t, rt.errors, 5)
+	assert.Equal(t, "entry \"foo\" has severity INFO, not ERROR", rt.errors[0])
+}
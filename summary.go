@@ -0,0 +1,131 @@
+package stackdriver
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type summary struct {
+	counts map[string]int64
+	period time.Duration
+}
+
+// MarshalLogObject adds the counts sorted by key so that the output is
+// deterministic.
+func (s *summary) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(s.counts))
+
+	for k := range s.counts {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	e.AddDuration("period", s.period)
+
+	return e.AddObject("counts", zapcore.ObjectMarshalerFunc(func(e zapcore.ObjectEncoder) error {
+		for _, k := range keys {
+			e.AddInt64(k, s.counts[k])
+		}
+
+		return nil
+	}))
+}
+
+// defaultSummaryInterval is the interval of StartSummaryLogger when the given
+// one isn't positive.
+const defaultSummaryInterval = time.Minute
+
+// SummaryLogger counts frequent events, such as errors by type, and logs the
+// counts as one entry every interval instead of an entry per event.
+type SummaryLogger struct {
+	logger  *zap.Logger
+	message string
+
+	mu     sync.Mutex
+	counts map[string]int64
+	since  time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// StartSummaryLogger logs the counts added to the returned SummaryLogger
+// every interval with message, under the "summary" key, along with the
+// period they were counted over. Nothing is logged for an interval without
+// counts. An interval of 0 or less defaults to a minute.
+func StartSummaryLogger(logger *zap.Logger, message string, interval time.Duration) *SummaryLogger {
+	if interval <= 0 {
+		interval = defaultSummaryInterval
+	}
+
+	s := &SummaryLogger{
+		logger:  logger,
+		message: message,
+		counts:  map[string]int64{},
+		since:   time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.flush()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+// Add adds n to the count of key.
+func (s *SummaryLogger) Add(key string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[key] += n
+}
+
+// Stop stops logging the counts and logs those added since the last entry.
+// It waits for the background goroutine to exit and is safe to call more
+// than once.
+func (s *SummaryLogger) Stop() {
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		s.flush()
+	})
+}
+
+func (s *SummaryLogger) flush() {
+	s.mu.Lock()
+	counts := s.counts
+	now := time.Now()
+	period := now.Sub(s.since)
+	s.counts = map[string]int64{}
+	s.since = now
+	s.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+
+	s.logger.Info(s.message, zap.Object("summary", &summary{
+		counts: counts,
+		period: period,
+	}))
+}
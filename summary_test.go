@@ -0,0 +1,67 @@
+package stackdriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSummaryLogger(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	s := StartSummaryLogger(zap.New(obs), "error summary", time.Millisecond)
+
+	total := func() map[string]int64 {
+		counts := map[string]int64{}
+
+		for _, entry := range logs.All() {
+			assert.Equal(t, "error summary", entry.Message)
+
+			summary := entry.ContextMap()["summary"].(map[string]interface{})
+			assert.Contains(t, summary, "period")
+
+			for k, v := range summary["counts"].(map[string]interface{}) {
+				counts[k] += v.(int64)
+			}
+		}
+
+		return counts
+	}
+
+	s.Add("timeout", 2)
+	s.Add("refused", 1)
+	s.Add("timeout", 1)
+
+	// Polling by hand, since Eventually can panic after returning in this
+	// version of testify.
+	for deadline := time.Now().Add(time.Second); logs.Len() == 0; {
+		require.True(t, time.Now().Before(deadline), "no summary logged")
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Add("timeout", 5)
+	s.Stop()
+	s.Stop()
+
+	count := logs.Len()
+	assert.Equal(t, map[string]int64{"timeout": 8, "refused": 1}, total())
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, count, logs.Len())
+}
+
+func TestSummaryLogger_DefaultInterval(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		s := StartSummaryLogger(zap.New(obs), "error summary", interval)
+		s.Add("timeout", 1)
+		s.Stop()
+	}
+
+	assert.Equal(t, 2, logs.Len())
+}
@@ -0,0 +1,206 @@
+package stackdriver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	headerTraceParent = "traceparent"
+	headerCloudTrace  = "X-Cloud-Trace-Context"
+)
+
+// LogTrace correlates the entry with a Cloud Trace trace. It logs nothing when
+// projectID is empty or traceID isn't a valid trace ID.
+func LogTrace(projectID, traceID string) zapcore.Field {
+	if projectID == "" || !isValidTraceID(traceID) {
+		return zap.Skip()
+	}
+
+	return zap.String(logKeyTrace, "projects/"+projectID+"/traces/"+traceID)
+}
+
+// LogTraceID correlates the entry with the Cloud Trace trace traceID of the
+// project set by WithProjectID or WithAutoDetect. It logs nothing when traceID
+// isn't a valid trace ID.
+func LogTraceID(traceID string) zapcore.Field {
+	if !isValidTraceID(traceID) {
+		return zap.Skip()
+	}
+
+	return zap.String(logKeyTrace, traceID)
+}
+
+// LogSpanID sets the span of the trace the entry belongs to. Cloud Logging
+// ignores a span ID without a trace, so it's only written along with LogTrace.
+func LogSpanID(spanID string) zapcore.Field {
+	return zap.String(logKeySpanID, spanID)
+}
+
+// LogTraceSampled marks the trace of the entry as sampled, meaning that it
+// was recorded by Cloud Trace. Like LogSpanID, it's only written along with
+// LogTrace.
+func LogTraceSampled(sampled bool) zapcore.Field {
+	return zap.Bool(logKeyTraceSampled, sampled)
+}
+
+type traceSpan struct {
+	trace   string
+	spanID  string
+	sampled bool
+}
+
+// LogTraceSpan correlates the entry with the span spanID of a Cloud Trace
+// trace in a single field, like LogTrace, LogSpanID and LogTraceSampled do
+// together. It logs nothing when projectID is empty or traceID isn't a valid
+// trace ID.
+func LogTraceSpan(projectID, traceID, spanID string, sampled bool) zapcore.Field {
+	if projectID == "" || !isValidTraceID(traceID) {
+		return zap.Skip()
+	}
+
+	return zapcore.Field{
+		Key:  logKeyTrace,
+		Type: zapcore.SkipType,
+		Interface: &traceSpan{
+			trace:   "projects/" + projectID + "/traces/" + traceID,
+			spanID:  spanID,
+			sampled: sampled,
+		},
+	}
+}
+
+// isValidTraceID reports whether id is made of 32 hexadecimal characters and
+// isn't all zeros.
+func isValidTraceID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+
+	zero := true
+
+	for _, c := range id {
+		switch {
+		case c == '0':
+		case '1' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+			zero = false
+		default:
+			return false
+		}
+	}
+
+	return !zero
+}
+
+// RequestTraceFields returns the trace, span and sampling fields of the trace
+// r belongs to, read from its W3C traceparent header or, failing that, its
+// X-Cloud-Trace-Context header. It returns nil when r isn't traced.
+func RequestTraceFields(r *http.Request, projectID string) []zapcore.Field {
+	return TraceFields(projectID, r.Header.Get(headerTraceParent), r.Header.Get(headerCloudTrace))
+}
+
+// TraceFields is RequestTraceFields for the values of the traceparent and
+// X-Cloud-Trace-Context headers read from elsewhere, such as the metadata of
+// a gRPC call.
+func TraceFields(projectID, traceParent, cloudTraceContext string) []zapcore.Field {
+	traceID, spanID, sampled, ok := parseTraceParent(traceParent)
+
+	if !ok {
+		traceID, spanID, sampled, ok = parseCloudTraceContext(cloudTraceContext)
+	}
+
+	if !ok || projectID == "" {
+		return nil
+	}
+
+	fields := []zapcore.Field{LogTrace(projectID, traceID)}
+
+	if spanID != "" {
+		fields = append(fields, LogSpanID(spanID))
+	}
+
+	if sampled {
+		fields = append(fields, LogTraceSampled(true))
+	}
+
+	return fields
+}
+
+// parseTraceParent parses a header formatted as
+// "VERSION-TRACE_ID-SPAN_ID-FLAGS". The trace is sampled when the lowest bit
+// of the flags is set.
+func parseTraceParent(header string) (traceID, spanID string, sampled, ok bool) {
+	parts := strings.Split(header, "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[2]) != 16 || !isValidTraceID(parts[1]) {
+		return "", "", false, false
+	}
+
+	if flags, err := strconv.ParseUint(parts[3], 16, 8); err == nil {
+		sampled = flags&1 == 1
+	}
+
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), sampled, true
+}
+
+// parseCloudTraceContext parses a header formatted as
+// "TRACE_ID/SPAN_ID;o=OPTIONS". The span ID is a decimal number, which is
+// converted to the 16 hexadecimal characters Cloud Logging expects. The trace
+// is sampled when the options are 1.
+func parseCloudTraceContext(header string) (traceID, spanID string, sampled, ok bool) {
+	if i := strings.IndexByte(header, ';'); i >= 0 {
+		sampled = header[i+1:] == "o=1"
+		header = header[:i]
+	}
+
+	traceID = header
+
+	if i := strings.IndexByte(header, '/'); i >= 0 {
+		traceID = header[:i]
+
+		if id, err := strconv.ParseUint(header[i+1:], 10, 64); err == nil && id != 0 {
+			spanID = fmt.Sprintf("%016x", id)
+		}
+	}
+
+	if !isValidTraceID(traceID) {
+		return "", "", false, false
+	}
+
+	return strings.ToLower(traceID), spanID, sampled, true
+}
+
+// SpanRef identifies a span of a trace.
+type SpanRef struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+func (s SpanRef) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("traceId", s.TraceID)
+	e.AddString("spanId", s.SpanID)
+	return nil
+}
+
+type spanRefs []SpanRef
+
+func (s spanRefs) MarshalLogArray(e zapcore.ArrayEncoder) error {
+	for _, ref := range s {
+		if err := e.AppendObject(ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogTraceLinks logs the spans related to the entry, such as the ones an
+// operation fanned out to, under the "links" key.
+func LogTraceLinks(links []SpanRef) zapcore.Field {
+	return zap.Array("links", spanRefs(links))
+}
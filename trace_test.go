@@ -0,0 +1,235 @@
+package stackdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestLogTrace(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		field := LogTrace("foo", testTraceID)
+		assert.Equal(t, zap.String(logKeyTrace, "projects/foo/traces/"+testTraceID), field)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.Equal(t, zap.Skip(), LogTrace("", testTraceID))
+		assert.Equal(t, zap.Skip(), LogTrace("foo", "bar"))
+		assert.Equal(t, zap.Skip(), LogTrace("foo", "00000000000000000000000000000000"))
+		assert.Equal(t, zap.Skip(), LogTrace("foo", "4bf92f3577b34da6a3ce929d0e0e473z"))
+	})
+}
+
+func TestLogSpanID(t *testing.T) {
+	field := LogSpanID("00f067aa0ba902b7")
+	assert.Equal(t, zap.String(logKeySpanID, "00f067aa0ba902b7"), field)
+}
+
+func TestLogTraceSampled(t *testing.T) {
+	assert.Equal(t, zap.Bool(logKeyTraceSampled, true), LogTraceSampled(true))
+}
+
+func TestLogTraceSpan(t *testing.T) {
+	assert.Equal(t, zap.Skip(), LogTraceSpan("", testTraceID, "bar", true))
+	assert.Equal(t, zap.Skip(), LogTraceSpan("foo", "bar", "bar", true))
+}
+
+func TestCore_Trace(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	errOutput := bytes.NewBuffer(nil)
+	core := newCore(writer)
+	core.ErrorOutput = zapcore.AddSync(errOutput)
+	logger := zap.New(core)
+
+	t.Run("Trace and span", func(t *testing.T) {
+		defer writer.Reset()
+		defer errOutput.Reset()
+
+		logger.With(LogTrace("foo", testTraceID)).Info("", LogSpanID("bar"))
+
+		var actual logEntry
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "projects/foo/traces/"+testTraceID, actual.Trace)
+		assert.Equal(t, "bar", actual.SpanID)
+		assert.Empty(t, errOutput.String())
+	})
+
+	t.Run("Trace span", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.Info("", LogTraceSpan("foo", testTraceID, "bar", true))
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.Equal(t, "projects/foo/traces/"+testTraceID, actual[logKeyTrace])
+		assert.Equal(t, "bar", actual[logKeySpanID])
+		assert.Equal(t, true, actual[logKeyTraceSampled])
+		assert.NotContains(t, actual["context"], "trace")
+		assert.NotContains(t, actual["message"], "trace")
+	})
+
+	t.Run("Sampled", func(t *testing.T) {
+		defer writer.Reset()
+
+		logger.With(LogTrace("foo", testTraceID), LogTraceSampled(true)).Info("")
+		logger.With(LogTrace("foo", testTraceID)).Info("", LogTraceSampled(false))
+		logger.Info("", LogTraceSampled(true))
+
+		lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		require.Len(t, lines, 3)
+
+		var sampled, unsampled logEntry
+		var untraced map[string]interface{}
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &sampled))
+		require.Nil(t, json.Unmarshal([]byte(lines[1]), &unsampled))
+		require.Nil(t, json.Unmarshal([]byte(lines[2]), &untraced))
+		assert.True(t, sampled.TraceSampled)
+		assert.False(t, unsampled.TraceSampled)
+		assert.NotContains(t, lines[1], logKeyTraceSampled)
+		assert.NotContains(t, untraced, logKeyTraceSampled)
+	})
+
+	t.Run("Span without trace", func(t *testing.T) {
+		defer writer.Reset()
+		defer errOutput.Reset()
+
+		logger.Info("", LogSpanID("bar"))
+
+		var actual map[string]interface{}
+		require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+		assert.NotContains(t, actual, logKeySpanID)
+		assert.Contains(t, errOutput.String(), `dropped span ID "bar"`)
+	})
+}
+
+func TestRequestTraceFields(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Header   map[string]string
+		Expected []zapcore.Field
+	}{
+		{
+			Name: "traceparent",
+			Header: map[string]string{
+				"traceparent": "00-" + testTraceID + "-00f067aa0ba902b7-01",
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+				LogSpanID("00f067aa0ba902b7"),
+				LogTraceSampled(true),
+			},
+		},
+		{
+			Name: "traceparent not sampled",
+			Header: map[string]string{
+				"traceparent": "00-" + testTraceID + "-00f067aa0ba902b7-00",
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+				LogSpanID("00f067aa0ba902b7"),
+			},
+		},
+		{
+			Name: "X-Cloud-Trace-Context",
+			Header: map[string]string{
+				"X-Cloud-Trace-Context": testTraceID + "/1;o=1",
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+				LogSpanID("0000000000000001"),
+				LogTraceSampled(true),
+			},
+		},
+		{
+			Name: "X-Cloud-Trace-Context not sampled",
+			Header: map[string]string{
+				"X-Cloud-Trace-Context": testTraceID + "/1;o=0",
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+				LogSpanID("0000000000000001"),
+			},
+		},
+		{
+			Name: "X-Cloud-Trace-Context without span",
+			Header: map[string]string{
+				"X-Cloud-Trace-Context": testTraceID,
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+			},
+		},
+		{
+			Name: "traceparent first",
+			Header: map[string]string{
+				"traceparent":           "00-" + testTraceID + "-00f067aa0ba902b7-01",
+				"X-Cloud-Trace-Context": "11111111111111111111111111111111/1",
+			},
+			Expected: []zapcore.Field{
+				LogTrace("foo", testTraceID),
+				LogSpanID("00f067aa0ba902b7"),
+				LogTraceSampled(true),
+			},
+		},
+		{
+			Name: "Invalid",
+			Header: map[string]string{
+				"traceparent":           "foo",
+				"X-Cloud-Trace-Context": "bar/1",
+			},
+		},
+		{
+			Name: "Missing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+
+			for k, v := range test.Header {
+				r.Header.Set(k, v)
+			}
+
+			assert.Equal(t, test.Expected, RequestTraceFields(r, "foo"))
+		})
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	assert.Equal(t, []zapcore.Field{
+		LogTrace("foo", testTraceID),
+		LogSpanID("0000000000000001"),
+	}, TraceFields("foo", "", testTraceID+"/1"))
+
+	assert.Nil(t, TraceFields("foo", "", ""))
+}
+
+func TestLogTraceLinks(t *testing.T) {
+	writer := bytes.NewBuffer(nil)
+	logger := zap.New(newCore(writer))
+
+	logger.Info("", LogTraceLinks([]SpanRef{
+		{TraceID: testTraceID, SpanID: "00f067aa0ba902b7"},
+		{TraceID: testTraceID, SpanID: "00f067aa0ba902b8"},
+	}))
+
+	var actual struct {
+		Links []map[string]string `json:"links"`
+	}
+
+	require.Nil(t, json.Unmarshal(writer.Bytes(), &actual))
+	assert.Equal(t, []map[string]string{
+		{"traceId": testTraceID, "spanId": "00f067aa0ba902b7"},
+		{"traceId": testTraceID, "spanId": "00f067aa0ba902b8"},
+	}, actual.Links)
+}
@@ -0,0 +1,71 @@
+package stackdriver
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type transaction struct {
+	id       string
+	amount   int64
+	currency string
+	status   string
+	visible  int
+}
+
+func (t *transaction) MarshalLogObject(e zapcore.ObjectEncoder) error {
+	e.AddString("id", maskID(t.id, t.visible))
+	e.AddInt64("amount", t.amount)
+	e.AddString("currency", t.currency)
+	e.AddString("status", t.status)
+	return nil
+}
+
+// maskID replaces all but the last visible characters of id with asterisks,
+// or all of them if id isn't longer than that, so it's never logged whole.
+func maskID(id string, visible int) string {
+	if visible < 0 {
+		visible = 0
+	}
+
+	runes := []rune(id)
+
+	if len(runes) <= visible {
+		return strings.Repeat("*", len(runes))
+	}
+
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
+
+type TransactionOption func(*transaction)
+
+// WithVisibleTransactionID keeps the last n characters of the transaction ID
+// unmasked instead of 4. With 0, or IDs no longer than n, the whole ID is
+// masked.
+func WithVisibleTransactionID(n int) TransactionOption {
+	return func(t *transaction) {
+		t.visible = n
+	}
+}
+
+// LogTransaction logs the outcome of a payment, amount being in the smallest
+// unit of currency, such as cents. All but the last 4 characters of id are
+// masked by default, in case it's a card number. There's no way to log more
+// card data.
+func LogTransaction(id string, amount int64, currency, status string, opts ...TransactionOption) zapcore.Field {
+	t := &transaction{
+		id:       id,
+		amount:   amount,
+		currency: currency,
+		status:   status,
+		visible:  4,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return zap.Object("transaction", t)
+}
@@ -0,0 +1,29 @@
+package stackdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogTransaction(t *testing.T) {
+	log := func(opts ...TransactionOption) map[string]interface{} {
+		enc := zapcore.NewMapObjectEncoder()
+		LogTransaction("txn_4242424242", 1999, "EUR", "captured", opts...).AddTo(enc)
+		return enc.Fields["transaction"].(map[string]interface{})
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"id":       "**********4242",
+		"amount":   int64(1999),
+		"currency": "EUR",
+		"status":   "captured",
+	}, log())
+
+	assert.Equal(t, "**************", log(WithVisibleTransactionID(0))["id"])
+	assert.Equal(t, "**************", log(WithVisibleTransactionID(20))["id"])
+	assert.Equal(t, "**", maskID("ab", 4))
+	assert.Equal(t, "****", maskID("abcd", 4))
+	assert.Equal(t, "****e", maskID("abcde", 1))
+}